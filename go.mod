@@ -2,10 +2,11 @@ module github.com/ashkan90/auto-core
 
 go 1.21.1
 
+require github.com/wI2L/jsondiff v0.5.1
+
 require (
 	github.com/tidwall/gjson v1.17.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
-	github.com/wI2L/jsondiff v0.5.1 // indirect
 )
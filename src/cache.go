@@ -3,6 +3,7 @@ package src
 import (
 	"github.com/ashkan90/auto-core/utils"
 	"log"
+	"sync"
 )
 
 type Cache struct {
@@ -38,3 +39,182 @@ func (c *Cache) Clone() *Cache {
 func (c *Cache) Reset() {
 	c.cache = utils.NewSyncMap()
 }
+
+// DependencyCache keeps the outputs of evaluated nodes together with the connections they
+// were computed from, so that a change invalidates only the downstream cone of the changed node.
+type DependencyCache struct {
+	outputs *Cache
+	lock    sync.Mutex
+	// sources nodes whose outputs have been consumed by the node
+	sources map[NodeId][]NodeId
+	// dependents nodes which have consumed the outputs of the node
+	dependents map[NodeId]map[NodeId]bool
+	// consumers node which has read its inputs through the connection
+	consumers map[ConnectionId]NodeId
+	// unwatch removes the subscriptions made by Watch
+	unwatch []func()
+}
+
+func NewDependencyCache() *DependencyCache {
+	return &DependencyCache{
+		outputs:    NewCache(),
+		sources:    make(map[NodeId][]NodeId),
+		dependents: make(map[NodeId]map[NodeId]bool),
+		consumers:  make(map[ConnectionId]NodeId),
+	}
+}
+
+// Get returns the cached outputs of the node.
+func (c *DependencyCache) Get(nodeID NodeId) (map[string]any, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	outputs, ok := c.outputs.Get(string(nodeID))
+	if !ok {
+		return nil, false
+	}
+	return outputs.(map[string]any), true
+}
+
+// Set caches the outputs of the node and records the connections its inputs were read from.
+func (c *DependencyCache) Set(nodeID NodeId, outputs map[string]any, conns []*Connection[ConnectionBase]) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, conn := range conns {
+		if c.dependents[conn.Source] == nil {
+			c.dependents[conn.Source] = make(map[NodeId]bool)
+		}
+		c.dependents[conn.Source][nodeID] = true
+		c.sources[nodeID] = append(c.sources[nodeID], conn.Source)
+		c.consumers[conn.E.ID] = nodeID
+	}
+
+	c.outputs.Set(string(nodeID), outputs)
+}
+
+// Invalidate drops the outputs of the node and of every node computed from them, directly or
+// not. It returns the ids of the dropped nodes.
+func (c *DependencyCache) Invalidate(nodeID NodeId) []NodeId {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.invalidate(nodeID)
+}
+
+// InvalidateConnection drops the node which has read its inputs through the connection and its
+// downstream cone.
+func (c *DependencyCache) InvalidateConnection(connID ConnectionId) []NodeId {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	consumer, ok := c.consumers[connID]
+	if !ok {
+		return nil
+	}
+	delete(c.consumers, connID)
+
+	return c.invalidate(consumer)
+}
+
+// Reset drops every cached output and dependency.
+func (c *DependencyCache) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.outputs.Reset()
+	c.sources = make(map[NodeId][]NodeId)
+	c.dependents = make(map[NodeId]map[NodeId]bool)
+	c.consumers = make(map[ConnectionId]NodeId)
+}
+
+// Watch subscribes the cache to the editor events which change the result of a node:
+// `connectionAdded`, `connectionRemoved`, `nodeRemoved` and `controlChanged`, also when they're
+// batched in a `transactionCommitted` event.
+func (c *DependencyCache) Watch(bus *EventBus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, eventType := range []EventType{"connectionAdded", "connectionRemoved", "nodeRemoved", "controlChanged"} {
+		c.unwatch = append(c.unwatch, bus.Subscribe(eventType, c.handle))
+	}
+	c.unwatch = append(c.unwatch, bus.Subscribe("transactionCommitted", func(event Event) {
+		if events, ok := event.Data.([]Event); ok {
+			for _, event := range events {
+				c.handle(event)
+			}
+		}
+	}))
+}
+
+// Close unsubscribes the cache from the buses it watches and drops every cached output.
+func (c *DependencyCache) Close() {
+	c.lock.Lock()
+	var unwatch = c.unwatch
+	c.unwatch = nil
+	c.lock.Unlock()
+
+	for _, unsubscribe := range unwatch {
+		unsubscribe()
+	}
+	c.Reset()
+}
+
+func (c *DependencyCache) handle(event Event) {
+	switch event.Type {
+	case "connectionAdded":
+		if conn, ok := event.Data.(*Connection[ConnectionBase]); ok {
+			c.Invalidate(conn.Target)
+		}
+	case "connectionRemoved":
+		if connID, ok := event.Data.(ConnectionId); ok {
+			c.InvalidateConnection(connID)
+		}
+	case "nodeRemoved":
+		if nodeID, ok := event.Data.(NodeId); ok {
+			c.Invalidate(nodeID)
+		}
+	case "controlChanged":
+		if change, ok := event.Data.(ControlChange); ok {
+			c.Invalidate(change.NodeId)
+		}
+	}
+}
+
+// invalidate walks the recorded dependents of the node. The caller must hold the lock.
+func (c *DependencyCache) invalidate(nodeID NodeId) []NodeId {
+	var removed []NodeId
+	var queue = []NodeId{nodeID}
+	var seen = map[NodeId]bool{nodeID: true}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if _, ok := c.outputs.Get(string(id)); ok {
+			c.outputs.Delete(string(id))
+			removed = append(removed, id)
+		}
+
+		for dependent := range c.dependents[id] {
+			if !seen[dependent] {
+				seen[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+		delete(c.dependents, id)
+
+		for _, source := range c.sources[id] {
+			delete(c.dependents[source], id)
+		}
+		delete(c.sources, id)
+	}
+
+	for connID, consumer := range c.consumers {
+		if seen[consumer] {
+			delete(c.consumers, connID)
+		}
+	}
+
+	return removed
+}
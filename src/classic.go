@@ -2,9 +2,11 @@ package src
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/ashkan90/auto-core/utils"
 	"log"
 	"sync"
+	"sync/atomic"
 )
 
 type PortId string
@@ -19,6 +21,82 @@ func NewSocket(name string) Socket {
 	}
 }
 
+// IsCompatibleWith reports whether an output with this socket can be connected to an input with the
+// given socket, according to DefaultSocketRegistry.
+func (s Socket) IsCompatibleWith(input Socket) bool {
+	return DefaultSocketRegistry.Compatible(s, input)
+}
+
+// SocketCompatibility reports whether an output socket can be connected to an input socket.
+type SocketCompatibility func(output, input Socket) bool
+
+// SocketRegistry decides which sockets can be connected. Sockets with the same name are always
+// compatible, the others only when one of the registered rules allows it.
+type SocketRegistry struct {
+	lock  sync.RWMutex
+	rules []SocketCompatibility
+}
+
+// DefaultSocketRegistry is used by NewConnection, TryNewConnection and by the editors which haven't got their own registry.
+var DefaultSocketRegistry = NewSocketRegistry()
+
+func NewSocketRegistry() *SocketRegistry {
+	return &SocketRegistry{}
+}
+
+// Register adds a custom compatibility rule.
+func (r *SocketRegistry) Register(rule SocketCompatibility) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.rules = append(r.rules, rule)
+}
+
+// Allow lets outputs with the `output` socket be connected to inputs with the `input` socket.
+func (r *SocketRegistry) Allow(output, input string) {
+	r.Register(func(o, i Socket) bool {
+		return o.Name == output && i.Name == input
+	})
+}
+
+// Compatible reports whether an output with the `output` socket can be connected to an input with the `input` socket.
+func (r *SocketRegistry) Compatible(output, input Socket) bool {
+	if output.Name == input.Name {
+		return true
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, rule := range r.rules {
+		if rule(output, input) {
+			return true
+		}
+	}
+	return false
+}
+
+// portOf returns the port of an input, output or port value stored in a node.
+func portOf(value any) (*Port[Socket], bool) {
+	switch p := value.(type) {
+	case *Input[Socket]:
+		return portOf(p.Port)
+	case *Output[Socket]:
+		return portOf(p.Port)
+	case *Port[Socket]:
+		return p, true
+	}
+	return nil, false
+}
+
+// socketOf returns the socket of an input, output or port value stored in a node.
+func socketOf(value any) (Socket, bool) {
+	if port, ok := portOf(value); ok {
+		return port.Socket, true
+	}
+	return Socket{}, false
+}
+
 type PortInterface interface {
 	GetId() string
 }
@@ -35,6 +113,11 @@ func (p *Port[S]) GetId() string {
 	return string(p.Id)
 }
 
+// IsCompatibleWith reports whether this output port can be connected to the given input port.
+func (p *Port[S]) IsCompatibleWith(input *Port[S]) bool {
+	return Socket(p.Socket).IsCompatibleWith(Socket(input.Socket))
+}
+
 func NewPort[S Socket](socket S, label string, multipleConnections bool) *Port[S] {
 	return &Port[S]{
 		Id:                  PortId(GetUID()),
@@ -152,6 +235,24 @@ type InputControl struct {
 	Options  *InputControlOptions `json:"options"`
 	Readonly *bool                `json:"readonly"`
 	Value    any                  `json:"value"`
+	// changed is bound by the node owning the control, it publishes `controlChanged` events
+	changed func(previous, value any)
+}
+
+// ControlChange is the data of `controlChanged` events published when InputControl.SetValue
+// is called on a control of a node which belongs to an editor.
+type ControlChange struct {
+	NodeId   NodeId
+	Key      string
+	Control  *InputControl
+	Previous any
+	Value    any
+}
+
+// PortRemoval is the data of `inputRemoved` and `outputRemoved` events.
+type PortRemoval struct {
+	NodeId NodeId
+	Key    string
 }
 
 func NewInputControl(_type InputControlType, opt *InputControlOptions) *InputControl {
@@ -173,10 +274,30 @@ func (ic *InputControl) GetValue() any {
 }
 
 func (ic *InputControl) SetValue(value any) {
+	previous := ic.Value
 	ic.Value = &value
 	if ic.Options.Change != nil {
 		ic.Options.Change(value)
 	}
+	if ic.changed != nil {
+		ic.changed(previous, value)
+	}
+}
+
+// restore puts back a value returned by GetValue as it was and notifies like SetValue does.
+func (ic *InputControl) restore(value any) {
+	previous := ic.Value
+	ic.Value = value
+	if ic.Options.Change != nil {
+		if pointer, ok := value.(*any); ok {
+			ic.Options.Change(*pointer)
+		} else {
+			ic.Options.Change(value)
+		}
+	}
+	if ic.changed != nil {
+		ic.changed(previous, value)
+	}
 }
 
 type Node[Base NodeBase] struct {
@@ -186,6 +307,8 @@ type Node[Base NodeBase] struct {
 	Controls *utils.SyncMap `json:"controls"`
 	Selected *bool          `json:"selected"`
 	mu       *sync.Mutex
+	// editor the node has been added to, nil when it's detached. It's changed while holding the editor's lock
+	editor atomic.Pointer[NodeEditor]
 }
 
 type NodeInterface interface {
@@ -221,7 +344,19 @@ func (n *Node[Base]) AddInput(k string, input InputInterface) {
 	n.Inputs.Add(k, input)
 }
 
+// RemoveInput removes the input. When the node belongs to an editor, the connections of the input are
+// removed first and `inputRemoved` is published. The input is kept, and the error logged, when a
+// subscriber cancels the removal of one of its connections.
 func (n *Node[Base]) RemoveInput(k string) {
+	for editor := n.editor.Load(); editor != nil; editor = n.editor.Load() {
+		handled, err := editor.removePort(&n.editor, NodeBase(n.E).ID, k, "input", n.Inputs)
+		if err != nil {
+			log.Println(err)
+		}
+		if handled {
+			return
+		}
+	}
 	n.Inputs.Delete(k)
 }
 
@@ -234,7 +369,19 @@ func (n *Node[Base]) AddOutput(k string, output PortInterface) {
 	n.Outputs.Add(k, output)
 }
 
+// RemoveOutput removes the output. When the node belongs to an editor, the connections of the output are
+// removed first and `outputRemoved` is published. The output is kept, and the error logged, when a
+// subscriber cancels the removal of one of its connections.
 func (n *Node[Base]) RemoveOutput(k string) {
+	for editor := n.editor.Load(); editor != nil; editor = n.editor.Load() {
+		handled, err := editor.removePort(&n.editor, NodeBase(n.E).ID, k, "output", n.Outputs)
+		if err != nil {
+			log.Println(err)
+		}
+		if handled {
+			return
+		}
+	}
 	n.Outputs.Delete(k)
 }
 
@@ -244,9 +391,29 @@ func (n *Node[Base]) HasControl(k string) bool {
 }
 
 func (n *Node[Base]) AddControl(k string, control ControlInterface) {
+	if ic, ok := control.(*InputControl); ok {
+		ic.changed = func(previous, value any) {
+			n.controlChanged(k, ic, previous, value)
+		}
+	}
 	n.Controls.Add(k, control)
 }
 
+func (n *Node[Base]) controlChanged(k string, control *InputControl, previous, value any) {
+	var editor = n.editor.Load()
+	if editor == nil {
+		return
+	}
+
+	editor.GetBus().Publish(Event{Type: "controlChanged", Data: ControlChange{
+		NodeId:   NodeBase(n.E).ID,
+		Key:      k,
+		Control:  control,
+		Previous: previous,
+		Value:    value,
+	}})
+}
+
 func (n *Node[Base]) RemoveControl(k string) {
 	n.Controls.Delete(k)
 }
@@ -285,13 +452,38 @@ type Connection[Base ConnectionBase] struct {
 	TargetInput  NodeId `json:"targetInput"`
 }
 
+// NewConnection creates a connection between an output of the source node and an input of the
+// target node. It panics when a port doesn't exist or the sockets aren't compatible according to
+// DefaultSocketRegistry, see TryNewConnection.
 func NewConnection(source NodeInterface, sourceOutput NodeId, target NodeInterface, targetInput NodeId) *Connection[ConnectionBase] {
-	var sourceNode, targetNode = source.Node(), target.Node()
-	if _, ok := sourceNode.Outputs.Get(string(sourceOutput)); !ok {
-		log.Panicf("source node doesn't have output with key %s", sourceOutput)
+	return NewConnectionWithRegistry(DefaultSocketRegistry, source, sourceOutput, target, targetInput)
+}
+
+// NewConnectionWithRegistry is like NewConnection but checks the sockets against the given registry,
+// e.g. the one passed to NodeEditor.SetSocketRegistry. A nil registry means DefaultSocketRegistry.
+func NewConnectionWithRegistry(registry *SocketRegistry, source NodeInterface, sourceOutput NodeId, target NodeInterface, targetInput NodeId) *Connection[ConnectionBase] {
+	conn, err := TryNewConnectionWithRegistry(registry, source, sourceOutput, target, targetInput)
+	if err != nil {
+		log.Panic(err)
 	}
-	if _, ok := targetNode.Inputs.Get(string(targetInput)); !ok {
-		log.Panicf("target node doesn't have input with key %s", targetInput)
+
+	return conn
+}
+
+// TryNewConnection is like NewConnection but returns an error instead of panicking.
+func TryNewConnection(source NodeInterface, sourceOutput NodeId, target NodeInterface, targetInput NodeId) (*Connection[ConnectionBase], error) {
+	return TryNewConnectionWithRegistry(DefaultSocketRegistry, source, sourceOutput, target, targetInput)
+}
+
+// TryNewConnectionWithRegistry is like NewConnectionWithRegistry but returns an error instead of panicking.
+func TryNewConnectionWithRegistry(registry *SocketRegistry, source NodeInterface, sourceOutput NodeId, target NodeInterface, targetInput NodeId) (*Connection[ConnectionBase], error) {
+	if registry == nil {
+		registry = DefaultSocketRegistry
+	}
+
+	var sourceNode, targetNode = source.Node(), target.Node()
+	if err := checkConnection(registry, sourceNode, string(sourceOutput), targetNode, string(targetInput)); err != nil {
+		return nil, err
 	}
 
 	return &Connection[ConnectionBase]{
@@ -300,5 +492,32 @@ func NewConnection(source NodeInterface, sourceOutput NodeId, target NodeInterfa
 		SourceOutput: sourceOutput,
 		Target:       targetNode.E.ID,
 		TargetInput:  targetInput,
+	}, nil
+}
+
+// checkConnection verifies the ports of a connection exist and their sockets are compatible.
+func checkConnection(registry *SocketRegistry, source *Node[NodeBase], sourceOutput string, target *Node[NodeBase], targetInput string) error {
+	output, ok := source.Outputs.Get(sourceOutput)
+	if !ok {
+		return fmt.Errorf("source node doesn't have output with key %s", sourceOutput)
 	}
+	input, ok := target.Inputs.Get(targetInput)
+	if !ok {
+		return fmt.Errorf("target node doesn't have input with key %s", targetInput)
+	}
+
+	outputSocket, outputOk := socketOf(output)
+	inputSocket, inputOk := socketOf(input)
+	if outputOk && inputOk && !registry.Compatible(outputSocket, inputSocket) {
+		return &IncompatibleSocketsError{
+			Source:       source.E.ID,
+			SourceOutput: sourceOutput,
+			OutputSocket: outputSocket,
+			Target:       target.E.ID,
+			TargetInput:  targetInput,
+			InputSocket:  inputSocket,
+		}
+	}
+
+	return nil
 }
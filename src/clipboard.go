@@ -0,0 +1,182 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// CopyOptions configures NodeEditor.Copy. Zero fields fall back to their defaults.
+type CopyOptions struct {
+	// Boundary keeps the connections between a copied node and a node outside of the selection.
+	// Once pasted, they're connected to the same outside node when it belongs to the editor, and
+	// dropped otherwise. Default is `false`, only the connections inside the selection are copied
+	Boundary bool
+}
+
+// Copy encodes the nodes and the connections between them into a clipboard which can be pasted
+// into any editor with Paste. opt can be nil.
+func (e *NodeEditor) Copy(nodeIDs []NodeId, opt *CopyOptions) ([]byte, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var data = jsonDocument{
+		Version:     FormatVersion,
+		Nodes:       make(map[NodeId]*Node[NodeBase]),
+		Connections: make(map[ConnectionId]*Connection[ConnectionBase]),
+	}
+
+	for _, nodeID := range nodeIDs {
+		node, exists := e.nodes[nodeID]
+		if !exists {
+			return nil, errors.New("node does not exist")
+		}
+		data.Nodes[nodeID] = node.Node()
+	}
+
+	for id, conn := range e.connections {
+		_, source := data.Nodes[conn.Source]
+		_, target := data.Nodes[conn.Target]
+		if (source && target) || (opt != nil && opt.Boundary && (source || target)) {
+			data.Connections[id] = conn
+		}
+	}
+
+	return json.Marshal(data)
+}
+
+// Paste decodes a clipboard made by Copy and adds its content to the editor in a single transaction.
+// Every node, port, control and connection gets a new id, the returned map gives the new id of every
+// pasted node by its id in the clipboard.
+func (e *NodeEditor) Paste(input []byte) (map[NodeId]NodeId, error) {
+	data, err := NewJSONEditorData(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids = remap(data)
+
+	pasted, err := NewJSONEditor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.Transaction(func(tx *NodeEditor) error {
+		var nodeIds = make([]NodeId, 0, len(pasted.Nodes))
+		for id := range pasted.Nodes {
+			nodeIds = append(nodeIds, id)
+		}
+		slices.Sort(nodeIds)
+
+		for _, id := range nodeIds {
+			if _, err := tx.AddNode(pasted.Nodes[id]); err != nil {
+				return err
+			}
+		}
+
+		var connIds = make([]ConnectionId, 0, len(pasted.Connections))
+		for id := range pasted.Connections {
+			connIds = append(connIds, id)
+		}
+		slices.Sort(connIds)
+
+		for _, id := range connIds {
+			var conn = pasted.Connections[id]
+			if !tx.hasNode(conn.Source) || !tx.hasNode(conn.Target) {
+				// a boundary connection whose outside node isn't in this editor
+				continue
+			}
+			if err := tx.AddConnection(conn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Duplicate copies the nodes and pastes them into the same editor, see Copy and Paste.
+func (e *NodeEditor) Duplicate(nodeIDs []NodeId, opt *CopyOptions) (map[NodeId]NodeId, error) {
+	clipboard, err := e.Copy(nodeIDs, opt)
+	if err != nil {
+		return nil, err
+	}
+	return e.Paste(clipboard)
+}
+
+func (e *NodeEditor) hasNode(nodeID NodeId) bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	_, exists := e.nodes[nodeID]
+	return exists
+}
+
+// remap gives new ids to the nodes, ports, controls and connections of the data and rewires the
+// connections to the new node ids. Connections to nodes which aren't in the data keep pointing to them.
+func remap(data *JSONEditorData) map[NodeId]NodeId {
+	var ids = make(map[NodeId]NodeId, len(data.Nodes))
+	var nodes = make(map[NodeId]*JSONEditorNode, len(data.Nodes))
+
+	for id, node := range data.Nodes {
+		var newID = NodeId(GetUID())
+		ids[id] = newID
+		nodes[newID] = node
+		node.Base.Id = newID
+		// pasted nodes aren't selected until Select publishes it
+		node.Selected = nil
+
+		for _, ports := range []map[string]any{node.Inputs, node.Outputs} {
+			for _, value := range ports {
+				if port, ok := value.(map[string]any); ok {
+					renewId(port["port"])
+					renewId(port["control"])
+				}
+			}
+		}
+		for _, value := range node.Controls {
+			if control, ok := value.(map[string]any); ok {
+				renewId(control["control"])
+			}
+		}
+	}
+
+	var rewire = func(id string) string {
+		if newID, ok := ids[NodeId(id)]; ok {
+			return string(newID)
+		}
+		return id
+	}
+
+	var connections = make(map[ConnectionId]*JSONEditorConnection, len(data.Connections))
+	for _, conn := range data.Connections {
+		var newID = GetUID()
+		conn.Base.Id = newID
+		conn.Source = rewire(conn.Source)
+		conn.Target = rewire(conn.Target)
+		if conn.Base.Source != "" {
+			conn.Base.Source = rewire(conn.Base.Source)
+		}
+		if conn.Base.Target != "" {
+			conn.Base.Target = rewire(conn.Base.Target)
+		}
+		connections[ConnectionId(newID)] = conn
+	}
+
+	data.Nodes = nodes
+	data.Connections = connections
+	return ids
+}
+
+// renewId replaces the `id` of a decoded port or control with a new one.
+func renewId(value any) {
+	if object, ok := value.(map[string]any); ok {
+		if _, ok := object["id"]; ok {
+			object["id"] = GetUID()
+		}
+	}
+}
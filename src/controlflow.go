@@ -0,0 +1,273 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// FlowSockets tells the engines which sockets carry control flow and which carry data.
+type FlowSockets struct {
+	// Control socket names of the control-flow ports. Default is `exec`
+	Control []string
+	// Data socket names of the data ports. When empty, every non control-flow socket is a data port
+	Data []string
+}
+
+// DefaultFlowSockets treats `exec` sockets as control flow and everything else as data.
+func DefaultFlowSockets() *FlowSockets {
+	return &FlowSockets{
+		Control: []string{"exec"},
+	}
+}
+
+// IsControl reports whether the socket is a control-flow socket.
+func (f *FlowSockets) IsControl(socket Socket) bool {
+	return slices.Contains(f.Control, socket.Name)
+}
+
+// IsData reports whether the socket is a data socket.
+func (f *FlowSockets) IsData(socket Socket) bool {
+	if len(f.Data) == 0 {
+		return !f.IsControl(socket)
+	}
+	return slices.Contains(f.Data, socket.Name)
+}
+
+// Inputs returns the sorted keys of the node inputs whose socket passes the filter.
+// Inputs without a recognizable socket are treated as data ports.
+func (f *FlowSockets) Inputs(node NodeInterface, filter func(Socket) bool) []string {
+	var keys []string
+	node.Node().Inputs.Range(func(key, value any) bool {
+		socket, ok := socketOf(value)
+		if !ok || filter(socket) {
+			keys = append(keys, key.(string))
+		}
+		return true
+	})
+	slices.Sort(keys)
+	return keys
+}
+
+// DataInputs returns the keys of the node inputs which are data ports.
+func (f *FlowSockets) DataInputs(node NodeInterface) []string {
+	return f.Inputs(node, f.IsData)
+}
+
+// ControlFlowOptions configures a ControlFlowEngine. Zero fields fall back to their defaults.
+type ControlFlowOptions struct {
+	// Sockets decides which ports are followed by the engine. Default is DefaultFlowSockets
+	Sockets *FlowSockets
+	// Trace publishes `nodeEvaluationStarted`, `nodeEvaluationFinished` and `nodeEvaluationFailed`
+	// events on the editor's bus for every executed node. Default is `false`
+	Trace bool
+	// Debugger pauses the run on its breakpoints. Default is `nil`, the run never pauses
+	Debugger *DebugSession
+	// MaxSteps maximum number of nodes executed by a run, the nodes forwarded to afterwards fail with
+	// ErrStepLimit. Default is `0`, a run goes on until every forwarded node is executed or it's cancelled
+	MaxSteps int
+}
+
+// ControlFlowEngine runs a graph by calling NodeExecutor.Execute of a node and following
+// every forward(output) call along the connections of that output.
+type ControlFlowEngine struct {
+	editor   *NodeEditor
+	sockets  *FlowSockets
+	trace    bool
+	debug    *DebugSession
+	maxSteps int
+}
+
+// NewControlFlowEngine creates a ControlFlowEngine working on the given editor. opt can be nil.
+func NewControlFlowEngine(editor *NodeEditor, opt *ControlFlowOptions) *ControlFlowEngine {
+	var engine = &ControlFlowEngine{
+		editor:  editor,
+		sockets: DefaultFlowSockets(),
+	}
+
+	if opt != nil && opt.Sockets != nil {
+		engine.sockets = opt.Sockets
+	}
+	if opt != nil {
+		engine.trace = opt.Trace
+		engine.debug = opt.Debugger
+		engine.maxSteps = opt.MaxSteps
+	}
+
+	return engine
+}
+
+// Execute calls Execute of the node with the given input key and, on every forward(output),
+// executes the targets connected to that output with their input keys. Forwarding an output
+// which isn't a control-flow port is reported as an error, forwarding an unknown output is a no-op.
+func (c *ControlFlowEngine) Execute(nodeID NodeId, input string) error {
+	return c.ExecuteContext(context.Background(), nodeID, input)
+}
+
+// ExecuteContext is like Execute but stops following forwards as soon as ctx is done.
+// Nodes which aren't executed because of it are reported with a *CancelledError.
+//
+// The forwarded nodes are executed once the forwarding node's Execute returns, one output after another
+// in the order they've been forwarded, and each of them with the nodes it forwards to before the next
+// one. The run keeps its pending nodes in a list rather than on the stack, so long chains and loops
+// don't grow the stack; see ControlFlowOptions.MaxSteps to stop endless loops.
+//
+// Nodes implementing NodeExecutorE may fail, a panic in an Execute method is a failure too. A failed
+// node doesn't stop the other forwarded branches, every failure is reported at the end in a *GraphError.
+func (c *ControlFlowEngine) ExecuteContext(ctx context.Context, nodeID NodeId, input string) error {
+	node, err := c.editor.GetNode(nodeID)
+	if err != nil {
+		return fmt.Errorf("node %s: %w", nodeID, err)
+	}
+
+	var run = &controlFlowRun{
+		ctx:    ctx,
+		engine: c,
+		tracer: newTracer(c.trace, c.editor.GetBus(), ctx, "controlflow"),
+	}
+
+	_, err = NewCancellable(ctx, func() (struct{}, error) {
+		run.run(node, input)
+		return struct{}{}, nil
+	})
+	if err != nil {
+		return cancelled(nodeID, err)
+	}
+	return run.failures.err()
+}
+
+// controlFlowRun holds the state of a single Execute call.
+type controlFlowRun struct {
+	ctx      context.Context
+	engine   *ControlFlowEngine
+	tracer   *tracer
+	failures failures
+	// pending steps of the run, the last one is taken first
+	pending []func()
+	steps   int
+}
+
+// run executes the node and every node forwarded to, until there's nothing left to do.
+func (r *controlFlowRun) run(node NodeInterface, input string) {
+	r.execute(node, input, nil, nil)
+
+	for len(r.pending) > 0 {
+		var step = r.pending[len(r.pending)-1]
+		r.pending[len(r.pending)-1] = nil
+		r.pending = r.pending[:len(r.pending)-1]
+		step()
+	}
+}
+
+// execute runs the node, via is the connection the node has been forwarded through, nil for the
+// first node, and path the nodes from the first node to the forwarding one. The forwarded nodes are
+// left pending, followed by the end of the node, which is taken once they're done.
+func (r *controlFlowRun) execute(node NodeInterface, input string, via *Connection[ConnectionBase], path *nodePath) {
+	var nodeID = node.Node().E.ID
+
+	if err := r.ctx.Err(); err != nil {
+		r.failures.add(nodeID, via, path, cancelled(nodeID, err))
+		return
+	}
+	if r.engine.maxSteps > 0 && r.steps >= r.engine.maxSteps {
+		r.failures.add(nodeID, via, path, ErrStepLimit)
+		return
+	}
+	r.steps++
+
+	var inputs = map[string]any{"input": input}
+
+	var frame = Frame{NodeId: nodeID, Engine: "controlflow", Stage: FrameEnter, Connection: via, Depth: path.len(), Inputs: inputs}
+	if err := r.engine.debug.hit(r.ctx, frame); err != nil {
+		r.failures.add(nodeID, via, path, cancelled(nodeID, err))
+		return
+	}
+
+	var (
+		own       []error
+		forwarded = make(map[string]any)
+		targets   []func()
+		trace     = r.tracer.start(nodeID, inputs)
+		next      = path.then(nodeID)
+	)
+
+	err := r.call(node, input, func(output string) {
+		if err := r.ctx.Err(); err != nil {
+			own = append(own, cancelled(nodeID, err))
+			return
+		}
+
+		port, ok := node.Node().Outputs.Get(output)
+		if !ok {
+			return
+		}
+
+		if socket, ok := socketOf(port); ok && !r.engine.sockets.IsControl(socket) {
+			own = append(own, fmt.Errorf("output %s is not a control-flow port", output))
+			return
+		}
+
+		count, _ := forwarded[output].(int)
+		forwarded[output] = count + 1
+
+		for _, conn := range r.engine.editor.GetConnectionsFrom(nodeID, []string{output}) {
+			conn := conn
+			targets = append(targets, func() {
+				target, err := r.engine.editor.GetNode(conn.Target)
+				if err != nil {
+					r.failures.add(conn.Target, conn, next, err)
+					return
+				}
+
+				r.execute(target, string(conn.TargetInput), conn, next)
+			})
+		}
+	})
+	if err != nil {
+		own = append(own, err)
+	}
+
+	var executed = time.Now()
+	r.pending = append(r.pending, func() {
+		var sources []NodeId
+		if via != nil {
+			sources = []NodeId{via.Source}
+		}
+		r.tracer.finish(trace, time.Since(executed), inputs, forwarded, sources, errors.Join(own...))
+
+		frame.Stage = FrameLeave
+		frame.Outputs = forwarded
+		frame.Err = errors.Join(own...)
+		if err := r.engine.debug.hit(r.ctx, frame); err != nil {
+			own = append(own, cancelled(nodeID, err))
+		}
+
+		if len(own) > 0 {
+			r.failures.add(nodeID, via, path, errors.Join(own...))
+		}
+	})
+
+	// the first forwarded node is taken first
+	for i := len(targets) - 1; i >= 0; i-- {
+		r.pending = append(r.pending, targets[i])
+	}
+}
+
+// call runs NodeExecutorE.ExecuteE of the node when it's implemented, NodeExecutor.Execute otherwise.
+// A panic is returned as an error.
+func (r *controlFlowRun) call(node NodeInterface, input string, forward func(output string)) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = recovered(value)
+		}
+	}()
+
+	if fallible, ok := node.(NodeExecutorE); ok {
+		return fallible.ExecuteE(input, forward)
+	}
+
+	node.Execute(input, forward)
+	return nil
+}
@@ -0,0 +1,526 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DataflowOptions configures a DataflowEngine. Zero fields fall back to their defaults.
+type DataflowOptions struct {
+	// Sockets decides which inputs are data ports. Default is DefaultFlowSockets
+	Sockets *FlowSockets
+	// Inputs returns the input keys of the node that should be resolved. Default is every data input of the node
+	Inputs func(node NodeInterface) []string
+	// Workers number of nodes evaluated at the same time. Independent upstream branches are fetched
+	// concurrently when it's greater than zero, so their evaluation order isn't deterministic anymore.
+	// Default is `0`, branches are fetched one after another in the order of NodeEditor.GetConnectionsTo
+	Workers int
+	// Trace publishes `nodeEvaluationStarted`, `nodeEvaluationFinished` and `nodeEvaluationFailed`
+	// events on the editor's bus for every evaluated node. Default is `false`
+	Trace bool
+	// Debugger pauses the run on its breakpoints. Default is `nil`, the run never pauses
+	Debugger *DebugSession
+}
+
+// DataflowEngine evaluates a graph by calling NodeData.Data of the requested node and
+// feeding it with the outputs of the upstream nodes connected to its inputs.
+type DataflowEngine struct {
+	editor  *NodeEditor
+	inputs  func(node NodeInterface) []string
+	workers int
+	trace   bool
+	debug   *DebugSession
+	cache   *DependencyCache
+	// acyclic is set when the data connections had no loop at the editor version `checked`
+	checks  sync.Mutex
+	checked uint64
+	acyclic bool
+}
+
+// NewDataflowEngine creates a DataflowEngine working on the given editor. opt can be nil. The engine
+// watches the changes of the editor to invalidate its cache until Close is called.
+func NewDataflowEngine(editor *NodeEditor, opt *DataflowOptions) *DataflowEngine {
+	var engine = &DataflowEngine{
+		editor: editor,
+		inputs: DefaultFlowSockets().DataInputs,
+		cache:  NewDependencyCache(),
+	}
+
+	if bus := editor.GetBus(); bus != nil {
+		engine.cache.Watch(bus)
+	}
+
+	if opt != nil && opt.Sockets != nil {
+		engine.inputs = opt.Sockets.DataInputs
+	}
+	if opt != nil && opt.Inputs != nil {
+		engine.inputs = opt.Inputs
+	}
+	if opt != nil && opt.Workers > 0 {
+		engine.workers = opt.Workers
+	}
+	if opt != nil {
+		engine.trace = opt.Trace
+		engine.debug = opt.Debugger
+	}
+
+	return engine
+}
+
+// Fetch evaluates the node and returns its outputs. Upstream nodes are evaluated lazily,
+// when the node calls the inputs function given to its Data method. Outputs are cached and
+// only recomputed once the node, or one of its upstream nodes, changes in the editor.
+//
+// Nodes implementing NodeDataE may fail, a panic in a Data method is a failure too. A failed node
+// doesn't stop the independent branches, every failure is reported at the end in a *GraphError.
+// The outputs are still returned when the node has coped with the failures of its upstream nodes,
+// but neither they nor the outputs computed from them are cached, so the next calls report the failures again.
+func (d *DataflowEngine) Fetch(nodeID NodeId) (map[string]any, error) {
+	return d.FetchContext(context.Background(), nodeID)
+}
+
+// FetchContext is like Fetch but stops as soon as ctx is done. Nodes which haven't been
+// evaluated yet, or which are waiting on their upstream nodes, stop with a *CancelledError.
+// A *CycleError is returned without evaluating anything when the upstream graph has loops.
+func (d *DataflowEngine) FetchContext(ctx context.Context, nodeID NodeId) (map[string]any, error) {
+	if cycles := d.upstreamCycles(nodeID); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+
+	var run = d.newRun(ctx)
+
+	outputs, err := NewCancellable(ctx, func() (map[string]any, error) {
+		return run.fetch(nodeID, nil, nil)
+	})
+	return outputs, run.result(nodeID, err)
+}
+
+// FetchInputs resolves the inputs of the node without calling its own Data method.
+// Every input key maps to a []any holding one value per connection.
+func (d *DataflowEngine) FetchInputs(nodeID NodeId) (map[string]any, error) {
+	return d.FetchInputsContext(context.Background(), nodeID)
+}
+
+// FetchInputsContext is like FetchInputs but stops as soon as ctx is done.
+func (d *DataflowEngine) FetchInputsContext(ctx context.Context, nodeID NodeId) (map[string]any, error) {
+	node, err := d.editor.GetNode(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", nodeID, err)
+	}
+
+	if cycles := d.upstreamCycles(nodeID); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+
+	var run = d.newRun(ctx)
+
+	inputs, err := NewCancellable(ctx, func() (map[string]any, error) {
+		inputs, _, err := run.fetchInputs(node, (*nodePath)(nil).then(nodeID))
+		return inputs, err
+	})
+	return inputs, run.result(nodeID, err)
+}
+
+// Invalidate drops the cached outputs of the node and of its downstream nodes.
+func (d *DataflowEngine) Invalidate(nodeID NodeId) {
+	d.cache.Invalidate(nodeID)
+}
+
+// Reset drops every cached output.
+func (d *DataflowEngine) Reset() {
+	d.cache.Reset()
+}
+
+// Close stops the engine from watching the editor's changes and drops every cached output.
+// The engine mustn't be used afterwards.
+func (d *DataflowEngine) Close() {
+	d.cache.Close()
+}
+
+// upstreamCycles returns the loops formed by the data connections feeding the node. The graph is
+// only scanned once per editor version as long as it has no loop at all.
+func (d *DataflowEngine) upstreamCycles(nodeID NodeId) [][]NodeId {
+	var version = d.editor.currentVersion()
+
+	d.checks.Lock()
+	var known = d.acyclic && d.checked == version
+	d.checks.Unlock()
+	if known {
+		return nil
+	}
+
+	var ids, sources = d.dataSources()
+	var edges = make(map[NodeId][]NodeId)
+	for _, id := range ids {
+		for _, source := range sources[id] {
+			edges[source] = append(edges[source], id)
+		}
+	}
+
+	if len(findCycles(ids, edges)) == 0 {
+		d.checks.Lock()
+		d.checked, d.acyclic = version, true
+		d.checks.Unlock()
+		return nil
+	}
+
+	var cone = map[NodeId]bool{nodeID: true}
+	var queue = []NodeId{nodeID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, source := range sources[id] {
+			if !cone[source] {
+				cone[source] = true
+				queue = append(queue, source)
+			}
+		}
+	}
+
+	ids = ids[:0]
+	edges = make(map[NodeId][]NodeId)
+	for id := range cone {
+		ids = append(ids, id)
+		for _, source := range sources[id] {
+			edges[source] = append(edges[source], id)
+		}
+	}
+	slices.Sort(ids)
+	for id := range edges {
+		slices.Sort(edges[id])
+	}
+
+	return findCycles(ids, edges)
+}
+
+// dataSources returns the sorted ids of the nodes having data inputs, with the source nodes
+// connected to them.
+func (d *DataflowEngine) dataSources() ([]NodeId, map[NodeId][]NodeId) {
+	var sources = make(map[NodeId][]NodeId)
+	var dataInputs = make(map[NodeId][]string)
+
+	for _, conn := range d.editor.GetConnections() {
+		keys, ok := dataInputs[conn.Target]
+		if !ok {
+			if target, err := d.editor.GetNode(conn.Target); err == nil {
+				keys = d.inputs(target)
+			}
+			dataInputs[conn.Target] = keys
+		}
+
+		if slices.Contains(keys, string(conn.TargetInput)) {
+			sources[conn.Target] = append(sources[conn.Target], conn.Source)
+		}
+	}
+
+	var ids = make([]NodeId, 0, len(sources))
+	for id := range sources {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids, sources
+}
+
+func (d *DataflowEngine) newRun(ctx context.Context) *dataflowRun {
+	var run = &dataflowRun{
+		ctx:     ctx,
+		engine:  d,
+		tracer:  newTracer(d.trace, d.editor.GetBus(), ctx, "dataflow"),
+		calls:   make(map[NodeId]*dataflowCall),
+		tainted: make(map[NodeId]bool),
+	}
+
+	if d.workers > 0 {
+		run.slots = make(chan struct{}, d.workers)
+	}
+
+	return run
+}
+
+// dataflowRun holds the state of a single Fetch call. Every node is evaluated once per run,
+// the consumers asking for a node which is being evaluated wait for its call to finish.
+type dataflowRun struct {
+	ctx      context.Context
+	engine   *DataflowEngine
+	tracer   *tracer
+	slots    chan struct{}
+	lock     sync.Mutex
+	calls    map[NodeId]*dataflowCall
+	failures failures
+	// tainted nodes whose outputs have been computed despite a failure upstream, they aren't cached
+	tainted map[NodeId]bool
+}
+
+type dataflowCall struct {
+	done    chan struct{}
+	outputs map[string]any
+	err     error
+}
+
+// fetch returns the outputs of the node, via is the connection the node is reached through
+// and path the nodes from the node the run has started with to the one reading the outputs.
+func (r *dataflowRun) fetch(nodeID NodeId, via *Connection[ConnectionBase], path *nodePath) (map[string]any, error) {
+	if outputs, ok := r.engine.cache.Get(nodeID); ok {
+		return outputs, nil
+	}
+
+	r.lock.Lock()
+	call, running := r.calls[nodeID]
+	if !running {
+		call = &dataflowCall{done: make(chan struct{})}
+		r.calls[nodeID] = call
+	}
+	r.lock.Unlock()
+
+	if running {
+		select {
+		case <-call.done:
+			return call.outputs, call.err
+		case <-r.ctx.Done():
+			return nil, r.failures.add(nodeID, via, path, cancelled(nodeID, r.ctx.Err()))
+		}
+	}
+
+	call.outputs, call.err = r.evaluate(nodeID, via, path)
+	close(call.done)
+
+	return call.outputs, call.err
+}
+
+// evaluate calls the Data method of the node. The returned error is the *NodeError of the node when it
+// has failed itself, or the errors of the upstream nodes it couldn't be computed without.
+func (r *dataflowRun) evaluate(nodeID NodeId, via *Connection[ConnectionBase], path *nodePath) (map[string]any, error) {
+	node, err := r.engine.editor.GetNode(nodeID)
+	if err != nil {
+		return nil, r.failures.add(nodeID, via, path, err)
+	}
+
+	// a cancelled run stops at the next node instead of evaluating the rest of the graph
+	if err := r.ctx.Err(); err != nil {
+		return nil, r.failures.add(nodeID, via, path, cancelled(nodeID, err))
+	}
+
+	var frame = Frame{NodeId: nodeID, Engine: "dataflow", Stage: FrameEnter, Connection: via, Depth: path.len()}
+	if err := r.engine.debug.hit(r.ctx, frame); err != nil {
+		return nil, r.failures.add(nodeID, via, path, cancelled(nodeID, err))
+	}
+
+	if err := r.acquire(); err != nil {
+		return nil, r.failures.add(nodeID, via, path, cancelled(nodeID, err))
+	}
+
+	var (
+		inputs    map[string]any
+		conns     []*Connection[ConnectionBase]
+		inputsErr error
+		resolved  bool
+		holding   = true
+		waited    time.Duration
+		trace     = r.tracer.start(nodeID, nil)
+	)
+
+	outputs, ownErr := r.call(node, func() (map[string]any, error) {
+		if resolved {
+			return inputs, inputsErr
+		}
+		resolved = true
+
+		// the slot is given back while waiting on the upstream nodes, so they can use it
+		r.release()
+		holding = false
+
+		waitStarted := time.Now()
+		defer func() {
+			waited += time.Since(waitStarted)
+		}()
+
+		inputs, conns, inputsErr = r.fetchInputs(node, path.then(nodeID))
+
+		if err := r.acquire(); err == nil {
+			holding = true
+		} else if inputsErr == nil {
+			inputsErr = err
+		}
+
+		if inputsErr != nil && isContextErr(inputsErr) && !errors.As(inputsErr, new(*NodeError)) {
+			inputsErr = r.failures.add(nodeID, via, path, cancelled(nodeID, inputsErr))
+		}
+		return inputs, inputsErr
+	})
+
+	if holding {
+		r.release()
+	}
+
+	_, fallible := node.(NodeDataE)
+
+	switch {
+	case ownErr != nil && ownErr != inputsErr:
+		err = r.failures.add(nodeID, via, path, ownErr)
+	case inputsErr != nil && (ownErr != nil || !fallible):
+		// a plain Data method can't tell whether it has coped with the failed inputs
+		err = inputsErr
+	}
+
+	if err != nil {
+		r.tracer.finish(trace, waited, inputs, nil, sourcesOf(conns), err)
+		r.leave(frame, inputs, nil, err)
+		return nil, err
+	}
+
+	if outputs == nil {
+		outputs = make(map[string]any)
+	}
+
+	r.tracer.finish(trace, waited, inputs, outputs, sourcesOf(conns), nil)
+	if err := r.leave(frame, inputs, outputs, nil); err != nil {
+		return nil, r.failures.add(nodeID, via, path, cancelled(nodeID, err))
+	}
+
+	switch {
+	case inputsErr != nil || r.isTainted(conns):
+		// the outputs would hide the failure from the next runs
+		r.taint(nodeID)
+	case r.ctx.Err() == nil:
+		// the caller of a cancelled run has already returned, its outputs aren't kept
+		r.engine.cache.Set(nodeID, outputs, conns)
+	}
+	return outputs, nil
+}
+
+// call runs NodeDataE.DataE of the node when it's implemented, NodeData.Data otherwise. A panic is returned as an error.
+func (r *dataflowRun) call(node NodeInterface, inputs func() (map[string]any, error)) (outputs map[string]any, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			outputs, err = nil, recovered(value)
+		}
+	}()
+
+	if fallible, ok := node.(NodeDataE); ok {
+		return fallible.DataE(inputs)
+	}
+
+	return node.Data(func() map[string]any {
+		values, _ := inputs()
+		return values
+	}), nil
+}
+
+// fetchInputs resolves the inputs of the node and returns the connections they were read from.
+// A failed upstream node doesn't stop the others from being fetched, their errors are joined.
+func (r *dataflowRun) fetchInputs(node NodeInterface, path *nodePath) (map[string]any, []*Connection[ConnectionBase], error) {
+	var keys = r.engine.inputs(node)
+	var inputs = make(map[string]any, len(keys))
+
+	for _, key := range keys {
+		inputs[key] = []any{}
+	}
+
+	var conns = r.engine.editor.GetConnectionsTo(node.Node().E.ID, keys)
+	var outputs = make([]map[string]any, len(conns))
+	var errs = make([]error, len(conns))
+
+	if r.slots == nil {
+		for i, conn := range conns {
+			outputs[i], errs[i] = r.fetch(conn.Source, conn, path)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i, conn := range conns {
+			wg.Add(1)
+			go func(i int, conn *Connection[ConnectionBase]) {
+				defer wg.Done()
+				outputs[i], errs[i] = r.fetch(conn.Source, conn, path)
+			}(i, conn)
+		}
+		wg.Wait()
+	}
+
+	var used []*Connection[ConnectionBase]
+	for i, conn := range conns {
+		if errs[i] != nil {
+			continue
+		}
+
+		key := string(conn.TargetInput)
+		inputs[key] = append(inputs[key].([]any), outputs[i][string(conn.SourceOutput)])
+		used = append(used, conn)
+	}
+
+	return inputs, used, errors.Join(errs...)
+}
+
+// result turns the error a run has ended with into the one returned to the caller: a *CancelledError when
+// the run has been stopped before it could finish, the *GraphError of the failed nodes otherwise.
+func (r *dataflowRun) result(nodeID NodeId, err error) error {
+	if err != nil && isContextErr(err) && !errors.As(err, new(*NodeError)) {
+		return cancelled(nodeID, err)
+	}
+	if failed := r.failures.err(); failed != nil {
+		return failed
+	}
+	return err
+}
+
+// isTainted reports whether one of the connections comes from a tainted node.
+func (r *dataflowRun) isTainted(conns []*Connection[ConnectionBase]) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return slices.ContainsFunc(conns, func(conn *Connection[ConnectionBase]) bool {
+		return r.tainted[conn.Source]
+	})
+}
+
+func (r *dataflowRun) taint(nodeID NodeId) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tainted[nodeID] = true
+}
+
+// leave gives the debugger the frame of an evaluated node.
+func (r *dataflowRun) leave(frame Frame, inputs, outputs map[string]any, err error) error {
+	frame.Stage = FrameLeave
+	frame.Inputs = inputs
+	frame.Outputs = outputs
+	frame.Err = err
+
+	return r.engine.debug.hit(r.ctx, frame)
+}
+
+// sourcesOf returns the distinct source nodes of the connections.
+func sourcesOf(conns []*Connection[ConnectionBase]) []NodeId {
+	var sources []NodeId
+	for _, conn := range conns {
+		if !slices.Contains(sources, conn.Source) {
+			sources = append(sources, conn.Source)
+		}
+	}
+	return sources
+}
+
+// acquire takes a worker slot, it's a no-op when the run isn't concurrent.
+func (r *dataflowRun) acquire() error {
+	if r.slots == nil {
+		return nil
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
+
+func (r *dataflowRun) release() {
+	if r.slots != nil {
+		<-r.slots
+	}
+}
@@ -0,0 +1,222 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// FrameStage tells whether a frame is taken before or after a node is evaluated.
+type FrameStage string
+
+const (
+	FrameEnter FrameStage = "enter"
+	FrameLeave FrameStage = "leave"
+)
+
+// Frame is a point of a run a DebugSession can pause at.
+type Frame struct {
+	NodeId NodeId
+	// Engine `dataflow` or `controlflow`
+	Engine string
+	Stage  FrameStage
+	// Connection the node has been reached through, nil for the node the run started with
+	Connection *Connection[ConnectionBase]
+	// Depth distance from the node the run started with
+	Depth int
+	// Inputs resolved inputs (dataflow, leave only) or the `input` key the node is executed with (control flow)
+	Inputs map[string]any
+	// Outputs outputs (dataflow) or the number of forwards of every output key (control flow), leave only
+	Outputs map[string]any
+	// Err error of the evaluation, leave only
+	Err error
+}
+
+type debugMode int
+
+const (
+	debugContinue debugMode = iota
+	debugStep
+	debugStepOver
+)
+
+// DebugSession pauses the engines it's given to on breakpoints and lets another goroutine
+// inspect the paused frame and resume the run with Step, StepOver or Continue.
+type DebugSession struct {
+	// gate lets only one goroutine of a run pause at a time
+	gate        sync.Mutex
+	lock        sync.Mutex
+	nodes       map[NodeId]bool
+	connections map[ConnectionId]bool
+	mode        debugMode
+	overDepth   int
+	current     *Frame
+	paused      chan struct{}
+	resume      chan struct{}
+}
+
+// NewDebugSession creates a session which runs until the first breakpoint.
+func NewDebugSession() *DebugSession {
+	return &DebugSession{
+		nodes:       make(map[NodeId]bool),
+		connections: make(map[ConnectionId]bool),
+		paused:      make(chan struct{}),
+	}
+}
+
+// AddBreakpoint pauses the run before the node is evaluated.
+func (s *DebugSession) AddBreakpoint(nodeID NodeId) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nodes[nodeID] = true
+}
+
+// RemoveBreakpoint removes the breakpoint of the node.
+func (s *DebugSession) RemoveBreakpoint(nodeID NodeId) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.nodes, nodeID)
+}
+
+// AddConnectionBreakpoint pauses the run before a node reached through the connection is evaluated.
+func (s *DebugSession) AddConnectionBreakpoint(connID ConnectionId) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.connections[connID] = true
+}
+
+// RemoveConnectionBreakpoint removes the breakpoint of the connection.
+func (s *DebugSession) RemoveConnectionBreakpoint(connID ConnectionId) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.connections, connID)
+}
+
+// Pause makes the run pause at the next frame.
+func (s *DebugSession) Pause() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.mode = debugStep
+}
+
+// Current returns the frame the run is paused at.
+func (s *DebugSession) Current() (Frame, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current == nil {
+		return Frame{}, false
+	}
+	return *s.current, true
+}
+
+// Wait blocks until the run pauses and returns the paused frame.
+func (s *DebugSession) Wait(ctx context.Context) (Frame, error) {
+	s.lock.Lock()
+	paused := s.paused
+	s.lock.Unlock()
+
+	select {
+	case <-paused:
+	case <-ctx.Done():
+		return Frame{}, ctx.Err()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current == nil {
+		return Frame{}, errors.New("debug session has been resumed")
+	}
+	return *s.current, nil
+}
+
+// Step resumes the run and pauses at the very next frame.
+func (s *DebugSession) Step() error {
+	return s.proceed(debugStep)
+}
+
+// StepOver resumes the run and pauses at the next frame which isn't nested in the current one.
+// Paused before a node, it's the frame taken once the node and its upstream (dataflow) or
+// forwarded (control flow) nodes are done.
+func (s *DebugSession) StepOver() error {
+	return s.proceed(debugStepOver)
+}
+
+// Continue resumes the run until the next breakpoint.
+func (s *DebugSession) Continue() error {
+	return s.proceed(debugContinue)
+}
+
+func (s *DebugSession) proceed(mode debugMode) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current == nil {
+		return errors.New("debug session isn't paused")
+	}
+
+	s.mode = mode
+	s.overDepth = s.current.Depth
+	s.current = nil
+	s.paused = make(chan struct{})
+	close(s.resume)
+
+	return nil
+}
+
+// hit is called by the engines at every frame and blocks while the run is paused. A nil session never pauses.
+func (s *DebugSession) hit(ctx context.Context, frame Frame) error {
+	if s == nil {
+		return nil
+	}
+
+	s.gate.Lock()
+	defer s.gate.Unlock()
+
+	s.lock.Lock()
+	if !s.shouldPause(frame) {
+		s.lock.Unlock()
+		return nil
+	}
+
+	var resume = make(chan struct{})
+	s.current = &frame
+	s.resume = resume
+	close(s.paused)
+	s.lock.Unlock()
+
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		if s.current == &frame {
+			s.current = nil
+			s.paused = make(chan struct{})
+		}
+		s.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// shouldPause must be called while holding the lock.
+func (s *DebugSession) shouldPause(frame Frame) bool {
+	switch {
+	case s.mode == debugStep:
+		return true
+	case s.mode == debugStepOver && frame.Depth <= s.overDepth:
+		return true
+	case frame.Stage != FrameEnter:
+		return false
+	case s.nodes[frame.NodeId]:
+		return true
+	}
+
+	return frame.Connection != nil && s.connections[frame.Connection.E.ID]
+}
@@ -0,0 +1,400 @@
+package src
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/ashkan90/auto-core/utils"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// decoder turns a decoded document into JSONEditorData, and the loosely typed JSONEditorData into nodes
+// and connections, collecting a DecodeError for every field it can't use instead of stopping or panicking.
+type decoder struct {
+	errors []*DecodeError
+}
+
+func (d *decoder) fail(pointer, format string, args ...any) {
+	d.errors = append(d.errors, &DecodeError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns a *DecodeErrors, nil if everything has been decoded.
+func (d *decoder) err() error {
+	if len(d.errors) == 0 {
+		return nil
+	}
+	return &DecodeErrors{Errors: d.errors}
+}
+
+// document turns a decoded document into JSONEditorData. Every member whose JSON type doesn't match the
+// field it's decoded into is reported, the version is left to the caller.
+func (d *decoder) document(document map[string]any) *JSONEditorData {
+	var data = &JSONEditorData{
+		Nodes:       make(map[NodeId]*JSONEditorNode),
+		Connections: make(map[ConnectionId]*JSONEditorConnection),
+	}
+
+	if nodes, ok := d.optionalObject(document["nodes"], "/nodes"); ok {
+		for _, id := range sortedKeys(nodes) {
+			if node, ok := d.nodeData(nodes[id], jsonPointer("", "nodes", id)); ok {
+				data.Nodes[NodeId(id)] = node
+			}
+		}
+	}
+
+	if connections, ok := d.optionalObject(document["connections"], "/connections"); ok {
+		for _, id := range sortedKeys(connections) {
+			if conn, ok := d.connectionData(connections[id], jsonPointer("", "connections", id)); ok {
+				data.Connections[ConnectionId(id)] = conn
+			}
+		}
+	}
+
+	return data
+}
+
+// nodeData decodes the members of a node, it's nil when the value is null. ok is false when a member
+// has been reported.
+func (d *decoder) nodeData(value any, pointer string) (data *JSONEditorNode, ok bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	object, ok := d.object(value, pointer)
+	if !ok {
+		return nil, false
+	}
+
+	var errs = len(d.errors)
+	data = &JSONEditorNode{}
+
+	if base, ok := d.optionalObject(object["base"], jsonPointer(pointer, "base")); ok && base != nil {
+		data.Base.Id = NodeId(d.optionalString(base, "id", jsonPointer(pointer, "base")))
+		data.Base.Type = d.optionalString(base, "type", jsonPointer(pointer, "base"))
+	}
+	data.Inputs, _ = d.optionalObject(object["inputs"], jsonPointer(pointer, "inputs"))
+	data.Outputs, _ = d.optionalObject(object["outputs"], jsonPointer(pointer, "outputs"))
+	data.Controls, _ = d.optionalObject(object["controls"], jsonPointer(pointer, "controls"))
+	data.Selected = d.optionalBool(object, "selected", pointer)
+
+	return data, len(d.errors) == errs
+}
+
+// connectionData decodes the members of a connection, it's nil when the value is null. ok is false when
+// a member has been reported.
+func (d *decoder) connectionData(value any, pointer string) (data *JSONEditorConnection, ok bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	object, ok := d.object(value, pointer)
+	if !ok {
+		return nil, false
+	}
+
+	var errs = len(d.errors)
+	data = &JSONEditorConnection{
+		Source:       d.optionalString(object, "source", pointer),
+		SourceOutput: d.optionalString(object, "sourceOutput", pointer),
+		Target:       d.optionalString(object, "target", pointer),
+		TargetInput:  d.optionalString(object, "targetInput", pointer),
+	}
+
+	if base, ok := d.optionalObject(object["base"], jsonPointer(pointer, "base")); ok && base != nil {
+		data.Base.Id = d.optionalString(base, "id", jsonPointer(pointer, "base"))
+		data.Base.Source = d.optionalString(base, "source", jsonPointer(pointer, "base"))
+		data.Base.Target = d.optionalString(base, "target", jsonPointer(pointer, "base"))
+	}
+
+	return data, len(d.errors) == errs
+}
+
+// addNode decodes the node, restores its type with the registry and adds it to the editor.
+func (d *decoder) addNode(editor *JSONEditor, registry *NodeRegistry, data *JSONEditorNode, id NodeId) {
+	var pointer = jsonPointer("", "nodes", string(id))
+
+	node := d.node(data, id, pointer)
+	if node == nil {
+		return
+	}
+
+	restored, err := registry.restore(node)
+	if err != nil {
+		d.fail(jsonPointer(pointer, "base", "type"), "%v", err)
+		return
+	}
+
+	editor.Nodes[id] = restored
+}
+
+// addConnection decodes the connection and adds it to the editor.
+func (d *decoder) addConnection(editor *JSONEditor, data *JSONEditorConnection, id ConnectionId) {
+	if conn := d.connection(data, id, jsonPointer("", "connections", string(id))); conn != nil {
+		editor.Connections[id] = conn
+	}
+}
+
+func (d *decoder) node(data *JSONEditorNode, id NodeId, pointer string) *Node[NodeBase] {
+	if data == nil {
+		d.fail(pointer, "expected an object, got null")
+		return nil
+	}
+
+	var node = &Node[NodeBase]{
+		E:        NodeBase{ID: id, Type: data.Base.Type},
+		Inputs:   utils.NewSyncMap(),
+		Outputs:  utils.NewSyncMap(),
+		Controls: utils.NewSyncMap(),
+		Selected: data.Selected,
+		mu:       &sync.Mutex{},
+	}
+
+	for _, key := range sortedKeys(data.Inputs) {
+		var pointer = jsonPointer(pointer, "inputs", key)
+		if port, control, showControl, label, ok := d.port(data.Inputs[key], pointer); ok {
+			node.Inputs.Add(key, &Input[Socket]{Port: port, Control: control, ShowControl: showControl, Label: label})
+		}
+	}
+
+	for _, key := range sortedKeys(data.Outputs) {
+		var pointer = jsonPointer(pointer, "outputs", key)
+		if port, control, showControl, label, ok := d.port(data.Outputs[key], pointer); ok {
+			node.Outputs.Add(key, &Output[Socket]{Port: port, Control: control, ShowControl: showControl, Label: label})
+		}
+	}
+
+	for _, key := range sortedKeys(data.Controls) {
+		if control := d.inputControl(data.Controls[key], jsonPointer(pointer, "controls", key)); control != nil {
+			node.AddControl(key, control)
+		}
+	}
+
+	return node
+}
+
+// port decodes an input or an output. A null value is skipped without an error.
+func (d *decoder) port(value any, pointer string) (port *Port[Socket], control ControlInterface, showControl bool, label string, ok bool) {
+	if value == nil {
+		return nil, nil, false, "", false
+	}
+
+	object, ok := d.object(value, pointer)
+	if !ok {
+		return nil, nil, false, "", false
+	}
+
+	var errs = len(d.errors)
+
+	if portObject, ok := d.object(object["port"], jsonPointer(pointer, "port")); ok {
+		var portPointer = jsonPointer(pointer, "port")
+		port = &Port[Socket]{
+			Id:                  PortId(d.string(portObject, "id", portPointer)),
+			Label:               d.string(portObject, "label", portPointer),
+			Index:               d.int(portObject, "index", portPointer),
+			MultipleConnections: d.bool(portObject, "multipleConnections", portPointer),
+		}
+		if socket, ok := d.object(portObject["socket"], jsonPointer(portPointer, "socket")); ok {
+			port.Socket.Name = d.string(socket, "name", jsonPointer(portPointer, "socket"))
+		}
+	}
+
+	if object["control"] != nil {
+		control = d.control(object["control"], jsonPointer(pointer, "control"))
+	}
+
+	showControl = d.bool(object, "showControl", pointer)
+	label = d.string(object, "label", pointer)
+
+	return port, control, showControl, label, len(d.errors) == errs
+}
+
+// control decodes a Control, nil if it's invalid.
+func (d *decoder) control(value any, pointer string) *Control {
+	object, ok := d.object(value, pointer)
+	if !ok {
+		return nil
+	}
+
+	var errs = len(d.errors)
+	var control = &Control{
+		Id:    d.string(object, "id", pointer),
+		Index: d.int(object, "index", pointer),
+	}
+	if len(d.errors) != errs {
+		return nil
+	}
+	return control
+}
+
+// inputControl decodes an InputControl. A null value is skipped without an error, missing
+// options and readonly flags are left empty.
+func (d *decoder) inputControl(value any, pointer string) *InputControl {
+	if value == nil {
+		return nil
+	}
+
+	object, ok := d.object(value, pointer)
+	if !ok {
+		return nil
+	}
+
+	var errs = len(d.errors)
+	var control = &InputControl{
+		Type:     InputControlType(d.string(object, "type", pointer)),
+		Options:  &InputControlOptions{},
+		Readonly: d.optionalBool(object, "readonly", pointer),
+		Value:    ToPtr(object["value"]),
+	}
+
+	if inner := d.control(object["control"], jsonPointer(pointer, "control")); inner != nil {
+		control.Control = inner
+	}
+
+	if object["options"] != nil {
+		if options, ok := d.object(object["options"], jsonPointer(pointer, "options")); ok {
+			control.Options.Readonly = d.optionalBool(options, "readonly", jsonPointer(pointer, "options"))
+			control.Options.Initial = options["initial"]
+		}
+	}
+
+	if len(d.errors) != errs {
+		return nil
+	}
+	return control
+}
+
+func (d *decoder) connection(data *JSONEditorConnection, id ConnectionId, pointer string) *Connection[ConnectionBase] {
+	if data == nil {
+		d.fail(pointer, "expected an object, got null")
+		return nil
+	}
+
+	var errs = len(d.errors)
+	for _, field := range []struct{ key, value string }{
+		{"source", data.Source},
+		{"sourceOutput", data.SourceOutput},
+		{"target", data.Target},
+		{"targetInput", data.TargetInput},
+	} {
+		if field.value == "" {
+			d.fail(jsonPointer(pointer, field.key), "expected a non-empty string")
+		}
+	}
+	if len(d.errors) != errs {
+		return nil
+	}
+
+	return &Connection[ConnectionBase]{
+		E: ConnectionBase{
+			ID:     id,
+			Source: NodeId(data.Base.Source),
+			Target: NodeId(data.Base.Target),
+		},
+		Source:       NodeId(data.Source),
+		SourceOutput: NodeId(data.SourceOutput),
+		Target:       NodeId(data.Target),
+		TargetInput:  NodeId(data.TargetInput),
+	}
+}
+
+func (d *decoder) object(value any, pointer string) (map[string]any, bool) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		d.fail(pointer, "expected an object, got %s", jsonType(value))
+	}
+	return object, ok
+}
+
+func (d *decoder) string(object map[string]any, key, pointer string) string {
+	value, ok := object[key].(string)
+	if !ok {
+		d.fail(jsonPointer(pointer, key), "expected a string, got %s", jsonType(object[key]))
+	}
+	return value
+}
+
+func (d *decoder) int(object map[string]any, key, pointer string) int {
+	value, ok := object[key].(float64)
+	if !ok || value != math.Trunc(value) || math.Abs(value) > math.MaxInt32 {
+		d.fail(jsonPointer(pointer, key), "expected an integer, got %s", jsonType(object[key]))
+		return 0
+	}
+	return int(value)
+}
+
+func (d *decoder) bool(object map[string]any, key, pointer string) bool {
+	value, ok := object[key].(bool)
+	if !ok {
+		d.fail(jsonPointer(pointer, key), "expected a boolean, got %s", jsonType(object[key]))
+	}
+	return value
+}
+
+// optionalObject decodes an object which can be missing or null, it's nil then.
+func (d *decoder) optionalObject(value any, pointer string) (map[string]any, bool) {
+	if value == nil {
+		return nil, true
+	}
+	return d.object(value, pointer)
+}
+
+// optionalString decodes a string which can be missing or null, it's empty then.
+func (d *decoder) optionalString(object map[string]any, key, pointer string) string {
+	if object[key] == nil {
+		return ""
+	}
+	return d.string(object, key, pointer)
+}
+
+// optionalBool decodes a boolean which can be missing or null.
+func (d *decoder) optionalBool(object map[string]any, key, pointer string) *bool {
+	if object[key] == nil {
+		return nil
+	}
+	value := d.bool(object, key, pointer)
+	return &value
+}
+
+// jsonType names the JSON type of a decoded value for error messages.
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		if value == math.Trunc(value) {
+			return fmt.Sprintf("the number %v", value)
+		}
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer appends the keys to the pointer, escaping them as RFC 6901 requires.
+func jsonPointer(pointer string, keys ...string) string {
+	for _, key := range keys {
+		pointer += "/" + pointerEscaper.Replace(key)
+	}
+	return pointer
+}
+
+// sortedKeys returns the keys of the map sorted, so errors are reported in a stable order.
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	var keys = make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
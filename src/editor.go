@@ -1,11 +1,13 @@
 package src
 
 import (
-	"encoding/json"
 	"errors"
+	"github.com/ashkan90/auto-core/utils"
 	"log"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type Root[Scheme BaseScheme[NodeBase, ConnectionBase]] struct {
@@ -19,14 +21,39 @@ type NodeEditor struct {
 	connections map[ConnectionId]*Connection[ConnectionBase]
 	lock        sync.RWMutex
 	eventBus    *EventBus
+	dag         bool
+	ordering    Ordering
+	sockets     *SocketRegistry
+	types       *NodeRegistry
+	policy      ConnectionPolicy
+	// links counts the connections going from a node to another, so DAG mode doesn't rebuild the adjacency
+	links map[NodeId]map[NodeId]int
+	// incoming and outgoing index the connections by their target and source, so the connections of a
+	// node are found without going through every connection
+	incoming connectionIndex
+	outgoing connectionIndex
+	// version is increased by every change, transactions use it to detect concurrent changes
+	version uint64
+	// staged is set on the views given to transactions, their events are collected in pending
+	staged  bool
+	pending []Event
+	// sequence last insertion sequence given to a node or connection
+	sequence            uint64
+	nodeSequences       map[NodeId]uint64
+	connectionSequences map[ConnectionId]uint64
 }
 
 // NewNodeEditor, yeni bir NodeEditor örneği oluşturur.
 func NewNodeEditor(bus *EventBus) *NodeEditor {
 	return &NodeEditor{
-		nodes:       make(map[NodeId]NodeInterface),
-		connections: make(map[ConnectionId]*Connection[ConnectionBase]),
-		eventBus:    bus,
+		nodes:               make(map[NodeId]NodeInterface),
+		connections:         make(map[ConnectionId]*Connection[ConnectionBase]),
+		eventBus:            bus,
+		links:               make(map[NodeId]map[NodeId]int),
+		incoming:            make(connectionIndex),
+		outgoing:            make(connectionIndex),
+		nodeSequences:       make(map[NodeId]uint64),
+		connectionSequences: make(map[ConnectionId]uint64),
 	}
 }
 
@@ -42,29 +69,60 @@ func Serialize(bus *EventBus, input *JSONEditor) *NodeEditor {
 		return NewNodeEditor(bus)
 	}
 
-	return &NodeEditor{
-		nodes:       input.Nodes,
-		connections: input.Connections,
-		eventBus:    bus,
+	var editor = &NodeEditor{
+		nodes:               input.Nodes,
+		connections:         input.Connections,
+		eventBus:            bus,
+		links:               make(map[NodeId]map[NodeId]int),
+		incoming:            make(connectionIndex),
+		outgoing:            make(connectionIndex),
+		nodeSequences:       make(map[NodeId]uint64),
+		connectionSequences: make(map[ConnectionId]uint64),
 	}
-}
 
-func (e *NodeEditor) Deserialize() string {
-	defer e.lock.RUnlock()
-	e.lock.RLock()
+	var nodeIds = make([]NodeId, 0, len(editor.nodes))
+	for id, node := range editor.nodes {
+		node.Node().editor.Store(editor)
+		nodeIds = append(nodeIds, id)
+	}
+	slices.Sort(nodeIds)
+	for _, id := range nodeIds {
+		editor.trackNode(id)
+	}
 
-	var m = map[string]any{
-		"nodes":       e.nodes,
-		"connections": e.connections,
+	var connIds = make([]ConnectionId, 0, len(editor.connections))
+	for id := range editor.connections {
+		connIds = append(connIds, id)
+	}
+	slices.Sort(connIds)
+	for _, id := range connIds {
+		editor.trackConnection(id)
+		editor.link(editor.connections[id])
 	}
 
-	contents, _ := json.Marshal(m)
+	return editor
+}
 
-	return string(contents)
+func (e *NodeEditor) Deserialize() string {
+	var contents strings.Builder
+	if err := e.Encode(&contents); err != nil {
+		log.Println(err)
+		return ""
+	}
+
+	return contents.String()
 }
 
-// AddNode, bir düğüm ekler.
+// AddNode, bir düğüm ekler. Önce nodeCreate sinyalini yayınlar.
 func (e *NodeEditor) AddNode(node NodeInterface) (NodeInterface, error) {
+	node, err := emitSignal(e.eventBus, "nodeCreate", node)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, errors.New("node is nil")
+	}
+
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
@@ -74,22 +132,51 @@ func (e *NodeEditor) AddNode(node NodeInterface) (NodeInterface, error) {
 		return nil, errors.New("node already exists")
 	}
 
+	// kurucusuyla oluşturulan düğümler de türleriyle yazılır
+	if n.E.Type == "" {
+		if name, ok := e.nodeRegistry().typeOf(node); ok {
+			n.E.Type = name
+		}
+	}
+
 	e.nodes[n.E.ID] = node
-	e.eventBus.Publish(Event{Type: "nodeCreated", Data: node})
+	e.trackNode(n.E.ID)
+	e.attach(n)
+	e.emit(Event{Type: "nodeCreated", Data: node})
 	return node, nil
 }
 
-// RemoveNode, bir düğümü kaldırır.
+// RemoveNode, bir düğümü bağlantılarıyla birlikte kaldırır. Önce nodeRemove sinyalini, sonra kaldırılacak
+// her bağlantı için connectionRemove sinyalini yayınlar. Sinyallerden biri engellenirse hiçbir şey kaldırılmaz.
 func (e *NodeEditor) RemoveNode(nodeID NodeId) (NodeId, error) {
-	e.lock.Lock()
+	nodeID, err := emitSignal(e.eventBus, "nodeRemove", nodeID)
+	if err != nil {
+		return "", err
+	}
+
+	err = e.lockRemoving(func() []*Connection[ConnectionBase] {
+		return e.nodeConnections(nodeID)
+	})
+	if err != nil {
+		return "", err
+	}
 	defer e.lock.Unlock()
 
-	if _, exists := e.nodes[nodeID]; !exists {
+	node, exists := e.nodes[nodeID]
+	if !exists {
 		return "", errors.New("node does not exist")
 	}
 
+	// düğüm bağlantılarından önce editörden ayrılır, böylece dinleyiciler bağlantıların düğümle birlikte
+	// kaldırıldığını anlayabilir
+	e.detach(node.Node())
+	for _, conn := range e.nodeConnections(nodeID) {
+		e.removeConnection(conn.E.ID)
+	}
+
 	delete(e.nodes, nodeID)
-	e.eventBus.Publish(Event{Type: "nodeRemoved", Data: nodeID})
+	delete(e.nodeSequences, nodeID)
+	e.emit(Event{Type: "nodeRemoved", Data: nodeID})
 	return nodeID, nil
 }
 
@@ -115,25 +202,77 @@ func (e *NodeEditor) GetNodes() []NodeInterface {
 	for _, node := range e.nodes {
 		nodes = append(nodes, node)
 	}
+	e.sortNodes(nodes)
 	return nodes
 }
 
-// AddConnection, bir bağlantı ekler.
+// AddConnection, bir bağlantı ekler. Önce connectionCreate sinyalini yayınlar, ConnectionPolicyReplace
+// ile yerine geçtiği bağlantılar için de connectionRemove yayınlanır.
 func (e *NodeEditor) AddConnection(conn *Connection[ConnectionBase]) error {
-	e.lock.Lock()
+	conn, err := emitSignal(e.eventBus, "connectionCreate", conn)
+	if err != nil {
+		return err
+	}
+	if conn == nil {
+		return errors.New("connection is nil")
+	}
+
+	err = e.lockRemoving(func() []*Connection[ConnectionBase] {
+		return e.replacedConnections(conn)
+	})
+	if err != nil {
+		return err
+	}
 	defer e.lock.Unlock()
 
 	if _, exists := e.connections[conn.E.ID]; exists {
 		return errors.New("connection already exists")
 	}
 
+	source, exists := e.nodes[conn.Source]
+	if !exists {
+		return errors.New("source node does not exist")
+	}
+	target, exists := e.nodes[conn.Target]
+	if !exists {
+		return errors.New("target node does not exist")
+	}
+
+	if err := checkConnection(e.socketRegistry(), source.Node(), string(conn.SourceOutput), target.Node(), string(conn.TargetInput)); err != nil {
+		return err
+	}
+
+	if e.dag {
+		if path := e.pathBetween(conn.Target, conn.Source); path != nil {
+			return &CycleError{Cycles: [][]NodeId{path}}
+		}
+	}
+
+	var occupied = e.occupiedPorts(conn, source.Node(), target.Node())
+	if len(occupied) > 0 && e.policy == ConnectionPolicyReject {
+		return occupied[0]
+	}
+
+	for _, port := range occupied {
+		for _, connID := range port.Connections {
+			e.removeConnection(connID)
+		}
+	}
+
 	e.connections[conn.E.ID] = conn
-	e.eventBus.Publish(Event{Type: "connectionAdded", Data: conn})
+	e.trackConnection(conn.E.ID)
+	e.link(conn)
+	e.emit(Event{Type: "connectionAdded", Data: conn})
 	return nil
 }
 
-// RemoveConnection, bir bağlantıyı kaldırır.
+// RemoveConnection, bir bağlantıyı kaldırır. Önce connectionRemove sinyalini yayınlar.
 func (e *NodeEditor) RemoveConnection(connID ConnectionId) error {
+	connID, err := emitSignal(e.eventBus, "connectionRemove", connID)
+	if err != nil {
+		return err
+	}
+
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
@@ -141,11 +280,71 @@ func (e *NodeEditor) RemoveConnection(connID ConnectionId) error {
 		return errors.New("connection does not exist")
 	}
 
-	delete(e.connections, connID)
-	e.eventBus.Publish(Event{Type: "connectionRemoved", Data: connID})
+	e.removeConnection(connID)
 	return nil
 }
 
+// lockRemoving, affected'ın döndürdüğü her bağlantı için connectionRemove sinyalini yayınlayıp kilidi alır.
+// Sinyaller kilit tutulmadan yayınlanır, kilit alındığında affected yeniden çağrılır ve bu arada eklenen
+// bağlantılar için de sinyal yayınlanır; böylece çağıran yalnızca sinyali yayınlanmış bağlantıları kaldırır.
+// Bir sinyal engellenirse hatayı döndürür ve kilit alınmamış olur. Dinleyicilerin değiştirdiği veri dikkate alınmaz.
+func (e *NodeEditor) lockRemoving(affected func() []*Connection[ConnectionBase]) error {
+	var signalled = make(map[ConnectionId]bool)
+	for {
+		e.lock.Lock()
+
+		var pending []ConnectionId
+		for _, conn := range affected() {
+			if !signalled[conn.E.ID] {
+				pending = append(pending, conn.E.ID)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		e.lock.Unlock()
+
+		for _, connID := range pending {
+			if _, err := emitSignal(e.eventBus, "connectionRemove", connID); err != nil {
+				return err
+			}
+			signalled[connID] = true
+		}
+	}
+}
+
+// replacedConnections, ConnectionPolicyReplace ile bağlantının yerine geçeceği bağlantıları döndürür.
+// Çağıranın kilidi tutması gerekir.
+func (e *NodeEditor) replacedConnections(conn *Connection[ConnectionBase]) []*Connection[ConnectionBase] {
+	source, sourceOk := e.nodes[conn.Source]
+	target, targetOk := e.nodes[conn.Target]
+	if e.policy != ConnectionPolicyReplace || !sourceOk || !targetOk {
+		return nil
+	}
+
+	var replaced []*Connection[ConnectionBase]
+	for _, port := range e.occupiedPorts(conn, source.Node(), target.Node()) {
+		for _, connID := range port.Connections {
+			replaced = append(replaced, e.connections[connID])
+		}
+	}
+	return replaced
+}
+
+// removeConnection, var olan bir bağlantıyı kaldırıp connectionRemoved yayınlar. Çağıranın kilidi tutması gerekir.
+func (e *NodeEditor) removeConnection(connID ConnectionId) {
+	conn, exists := e.connections[connID]
+	if !exists {
+		return
+	}
+
+	delete(e.connections, connID)
+	e.unlink(conn)
+	delete(e.connectionSequences, connID)
+	e.emit(Event{Type: "connectionRemoved", Data: connID})
+}
+
 // GetConnection, belirtilen ID'ye sahip bir bağlantıyı döndürür.
 func (e *NodeEditor) GetConnection(connID ConnectionId) (*Connection[ConnectionBase], error) {
 	e.lock.RLock()
@@ -168,6 +367,7 @@ func (e *NodeEditor) GetConnections() []*Connection[ConnectionBase] {
 	for _, conn := range e.connections {
 		conns = append(conns, conn)
 	}
+	e.sortConnections(conns)
 	return conns
 }
 
@@ -176,13 +376,93 @@ func (e *NodeEditor) GetConnectionsTo(nodeID NodeId, inputKeys []string) []*Conn
 	e.lock.RLock()
 	defer e.lock.RUnlock()
 
-	conns := make([]*Connection[ConnectionBase], 0, len(e.connections))
-	for _, conn := range e.connections {
-		if conn.Target == nodeID && slices.Contains(inputKeys, string(conn.TargetInput)) {
-			conns = append(conns, conn)
+	return e.incoming.connections(nodeID, e, func(conn *Connection[ConnectionBase]) bool {
+		return slices.Contains(inputKeys, string(conn.TargetInput))
+	})
+}
+
+// GetConnectionsFrom verilen node'un belirtilen çıkışlarından çıkan tüm bağlantıları döndürür.
+func (e *NodeEditor) GetConnectionsFrom(nodeID NodeId, outputKeys []string) []*Connection[ConnectionBase] {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.outgoing.connections(nodeID, e, func(conn *Connection[ConnectionBase]) bool {
+		return slices.Contains(outputKeys, string(conn.SourceOutput))
+	})
+}
+
+// removePort, düğümün bir girişini ya da çıkışını önce bağlantılarını kaldırarak siler ve
+// inputRemoved ya da outputRemoved yayınlar. side `input` ya da `output` olmalıdır. Bağlantılar için
+// connectionRemove yayınlanır, biri engellenirse hiçbir şey silinmez ve hata döndürülür. Düğüm kilit
+// alındığında artık bu editöre ait değilse hiçbir şey yapmaz ve false döndürür.
+func (e *NodeEditor) removePort(owner *atomic.Pointer[NodeEditor], nodeID NodeId, key, side string, ports *utils.SyncMap) (bool, error) {
+	var connections = func() []*Connection[ConnectionBase] {
+		if owner.Load() != e {
+			return nil
+		}
+		if side == "input" {
+			return e.incoming.connections(nodeID, e, func(conn *Connection[ConnectionBase]) bool {
+				return string(conn.TargetInput) == key
+			})
 		}
+		return e.outgoing.connections(nodeID, e, func(conn *Connection[ConnectionBase]) bool {
+			return string(conn.SourceOutput) == key
+		})
 	}
-	return conns
+
+	if err := e.lockRemoving(connections); err != nil {
+		return true, err
+	}
+	defer e.lock.Unlock()
+
+	if owner.Load() != e {
+		return false, nil
+	}
+	if _, exists := ports.Get(key); !exists {
+		return true, nil
+	}
+
+	for _, conn := range connections() {
+		e.removeConnection(conn.E.ID)
+	}
+
+	ports.Delete(key)
+	e.emit(Event{Type: EventType(side + "Removed"), Data: PortRemoval{NodeId: nodeID, Key: key}})
+	return true, nil
+}
+
+// SetSocketRegistry, AddConnection'ın soket uyumluluğunu kontrol ederken kullandığı kayıt defterini değiştirir.
+// nil verilirse DefaultSocketRegistry kullanılır.
+func (e *NodeEditor) SetSocketRegistry(registry *SocketRegistry) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.sockets = registry
+}
+
+// socketRegistry, editörün kullandığı kayıt defterini döndürür. Çağıranın kilidi tutması gerekir.
+func (e *NodeEditor) socketRegistry() *SocketRegistry {
+	if e.sockets == nil {
+		return DefaultSocketRegistry
+	}
+	return e.sockets
+}
+
+// SetNodeRegistry, AddNode'un türü olmayan düğümlere Go türlerinden tür adı verirken kullandığı kayıt defterini
+// değiştirir. nil verilirse DefaultNodeRegistry kullanılır.
+func (e *NodeEditor) SetNodeRegistry(registry *NodeRegistry) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.types = registry
+}
+
+// nodeRegistry, editörün kullandığı düğüm kayıt defterini döndürür. Çağıranın kilidi tutması gerekir.
+func (e *NodeEditor) nodeRegistry() *NodeRegistry {
+	if e.types == nil {
+		return DefaultNodeRegistry
+	}
+	return e.types
 }
 
 func (e *NodeEditor) GetBus() *EventBus {
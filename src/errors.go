@@ -0,0 +1,262 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CancelledError is returned when the evaluation of a node is stopped because its context is done.
+// It unwraps to the context error, so errors.Is(err, context.Canceled) keeps working.
+type CancelledError struct {
+	NodeId NodeId
+	Err    error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("node %s: evaluation cancelled: %v", e.NodeId, e.Err)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// NodeError is the failure of a single node during a run.
+type NodeError struct {
+	NodeId NodeId
+	// Input key of the downstream node the failure has reached through, empty for the node a run has started with
+	Input string
+	// Path nodes from the node a run has started with to the failed node, both included
+	Path []NodeId
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	if e.Input != "" {
+		return fmt.Sprintf("node %s (input %s): %v", e.NodeId, e.Input, e.Err)
+	}
+	return fmt.Sprintf("node %s: %v", e.NodeId, e.Err)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// GraphError aggregates the failures of every node during a run.
+type GraphError struct {
+	// Errors failures in the order they have happened
+	Errors []*NodeError
+}
+
+func (e *GraphError) Error() string {
+	var messages = make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d node(s) failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+func (e *GraphError) Unwrap() []error {
+	var errs = make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// nodePath is the path a run has taken to a node, every node is linked to the one before it so the
+// path is extended without being copied. A nil path is empty.
+type nodePath struct {
+	parent *nodePath
+	nodeID NodeId
+	depth  int
+}
+
+// then returns the path extended with the node.
+func (p *nodePath) then(nodeID NodeId) *nodePath {
+	return &nodePath{parent: p, nodeID: nodeID, depth: p.len() + 1}
+}
+
+// len returns the number of nodes on the path.
+func (p *nodePath) len() int {
+	if p == nil {
+		return 0
+	}
+	return p.depth
+}
+
+// ids returns the nodes of the path, from the first one.
+func (p *nodePath) ids() []NodeId {
+	var ids = make([]NodeId, p.len())
+	for ; p != nil; p = p.parent {
+		ids[p.depth-1] = p.nodeID
+	}
+	return ids
+}
+
+// failures collects the node errors of a run, once per node.
+type failures struct {
+	lock sync.Mutex
+	list []*NodeError
+}
+
+func (f *failures) add(nodeID NodeId, via *Connection[ConnectionBase], path *nodePath, err error) *NodeError {
+	var nodeErr = &NodeError{
+		NodeId: nodeID,
+		Path:   path.then(nodeID).ids(),
+		Err:    err,
+	}
+	if via != nil {
+		nodeErr.Input = string(via.TargetInput)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, known := range f.list {
+		if known.NodeId == nodeID {
+			return known
+		}
+	}
+
+	f.list = append(f.list, nodeErr)
+	return nodeErr
+}
+
+// err returns a *GraphError, nil if nothing has failed.
+func (f *failures) err() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if len(f.list) == 0 {
+		return nil
+	}
+	return &GraphError{Errors: slices.Clone(f.list)}
+}
+
+// recovered turns a panic of a node into an error.
+func recovered(value any) error {
+	if err, ok := value.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", value)
+}
+
+// CycleError is returned when a graph, or a connection being added to it, contains loops.
+type CycleError struct {
+	// Cycles node ids of every loop
+	Cycles [][]NodeId
+}
+
+func (e *CycleError) Error() string {
+	var loops = make([]string, 0, len(e.Cycles))
+	for _, cycle := range e.Cycles {
+		var ids = make([]string, 0, len(cycle)+1)
+		for _, id := range cycle {
+			ids = append(ids, string(id))
+		}
+		if len(cycle) > 0 {
+			ids = append(ids, string(cycle[0]))
+		}
+		loops = append(loops, strings.Join(ids, " -> "))
+	}
+	return fmt.Sprintf("graph contains %d cycle(s): %s", len(e.Cycles), strings.Join(loops, ", "))
+}
+
+// IncompatibleSocketsError is returned when an output is connected to an input whose socket isn't compatible.
+type IncompatibleSocketsError struct {
+	Source       NodeId
+	SourceOutput string
+	OutputSocket Socket
+	Target       NodeId
+	TargetInput  string
+	InputSocket  Socket
+}
+
+func (e *IncompatibleSocketsError) Error() string {
+	return fmt.Sprintf("output %s of node %s with socket %q can't be connected to input %s of node %s with socket %q",
+		e.SourceOutput, e.Source, e.OutputSocket.Name, e.TargetInput, e.Target, e.InputSocket.Name)
+}
+
+// PortOccupiedError is returned when a port which doesn't accept multiple connections is already connected.
+type PortOccupiedError struct {
+	NodeId NodeId
+	Key    string
+	// Side `input` or `output`
+	Side string
+	// Connections existing connections of the port
+	Connections []ConnectionId
+}
+
+func (e *PortOccupiedError) Error() string {
+	return fmt.Sprintf("%s %s of node %s doesn't accept multiple connections", e.Side, e.Key, e.NodeId)
+}
+
+// SignalCancelledError is returned by the editor when a subscriber of a pre-mutation event has cancelled the change.
+type SignalCancelledError struct {
+	Type EventType
+	Err  error
+}
+
+func (e *SignalCancelledError) Error() string {
+	return fmt.Sprintf("%s has been cancelled: %v", e.Type, e.Err)
+}
+
+func (e *SignalCancelledError) Unwrap() error {
+	return e.Err
+}
+
+// ErrStepLimit is reported for the nodes a ControlFlowEngine doesn't execute once ControlFlowOptions.MaxSteps is reached.
+var ErrStepLimit = errors.New("step limit has been reached")
+
+// ErrTransactionConflict is returned by NodeEditor.Transaction when the editor has been changed while the transaction was running.
+var ErrTransactionConflict = errors.New("editor has been changed during the transaction")
+
+// DecodeError describes a field of an encoded graph which can't be decoded.
+type DecodeError struct {
+	// Pointer JSON pointer (RFC 6901) to the field, e.g. `/nodes/abc/inputs/exec/port/label`
+	Pointer string
+	Message string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Message)
+}
+
+// DecodeErrors aggregates every problem found while decoding a graph.
+type DecodeErrors struct {
+	// Errors problems sorted by the position of their field
+	Errors []*DecodeError
+}
+
+func (e *DecodeErrors) Error() string {
+	var messages = make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d decoding problem(s): %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+func (e *DecodeErrors) Unwrap() []error {
+	var errs = make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// cancelled wraps a context error into a CancelledError unless it's already one.
+func cancelled(nodeID NodeId, err error) error {
+	var cancelErr *CancelledError
+	if errors.As(err, &cancelErr) {
+		return err
+	}
+	return &CancelledError{NodeId: nodeID, Err: err}
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,359 @@
+package src
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// HistoryOptions configures a History. Zero fields fall back to their defaults.
+type HistoryOptions struct {
+	// Depth maximum number of undoable steps, the oldest ones are dropped. Default is 100
+	Depth int
+}
+
+// History records the changes of an editor through the events it publishes and lets them be undone
+// and redone. It records node and connection additions and removals, value changes made with
+// InputControl.SetValue on the nodes of the editor, and every committed transaction as a single step.
+// Connections removed together with their node are restored when the node removal is undone.
+//
+// Changes to the ports of the nodes aren't recorded. Events published while an undo or a redo is
+// applied aren't recorded either, so the editor shouldn't be changed concurrently in the meantime.
+type History struct {
+	editor *NodeEditor
+	depth  int
+
+	lock       sync.Mutex
+	undo, redo []historyStep
+	// group collects the actions of the running Group calls, nil when there's none
+	group    *historyStep
+	groups   int
+	applying bool
+	// nodes and connections mirror the editor, as the removal events only carry ids
+	nodes       map[NodeId]NodeInterface
+	connections map[ConnectionId]*Connection[ConnectionBase]
+	// cascaded connections removed together with a node, waiting for its `nodeRemoved` event
+	cascaded []*Connection[ConnectionBase]
+	unwatch  []func()
+}
+
+// historyStep is the list of the actions undone and redone at once, in the order they have happened.
+type historyStep []historyAction
+
+type historyAction interface {
+	undo(editor *NodeEditor) error
+	redo(editor *NodeEditor) error
+}
+
+// NewHistory creates a History starting from the current state of the editor. It records the changes
+// until Close is called. opt can be nil.
+func NewHistory(editor *NodeEditor, opt *HistoryOptions) *History {
+	var history = &History{
+		editor:      editor,
+		depth:       100,
+		nodes:       make(map[NodeId]NodeInterface),
+		connections: make(map[ConnectionId]*Connection[ConnectionBase]),
+	}
+
+	if opt != nil && opt.Depth > 0 {
+		history.depth = opt.Depth
+	}
+
+	for _, node := range editor.GetNodes() {
+		history.nodes[node.Node().E.ID] = node
+	}
+	for _, conn := range editor.GetConnections() {
+		history.connections[conn.E.ID] = conn
+	}
+
+	var bus = editor.GetBus()
+	for _, eventType := range []EventType{"nodeCreated", "nodeRemoved", "connectionAdded", "connectionRemoved", "controlChanged", "transactionCommitted"} {
+		history.unwatch = append(history.unwatch, bus.Subscribe(eventType, history.handle))
+	}
+
+	return history
+}
+
+// Group records the changes made by fn as a single step, even when fn fails. Groups can be nested,
+// the outermost one makes the step.
+func (h *History) Group(fn func() error) error {
+	h.lock.Lock()
+	if h.groups == 0 {
+		h.group = &historyStep{}
+	}
+	h.groups++
+	h.lock.Unlock()
+
+	defer func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+
+		h.groups--
+		if h.groups == 0 {
+			var step = *h.group
+			h.group = nil
+			h.push(step)
+		}
+	}()
+
+	return fn()
+}
+
+// CanUndo reports whether there's a step to undo.
+func (h *History) CanUndo() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return len(h.undo) > 0
+}
+
+// CanRedo reports whether there's a step to redo.
+func (h *History) CanRedo() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return len(h.redo) > 0
+}
+
+// Undo reverts the last recorded step. When an action of the step fails, the actions reverted
+// before it stay reverted, the step is dropped and the error is returned.
+func (h *History) Undo() error {
+	return h.apply(&h.undo, &h.redo, func(step historyStep) error {
+		for i := len(step) - 1; i >= 0; i-- {
+			if err := step[i].undo(h.editor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Redo applies again the last undone step. Recording a new step drops the undone ones.
+func (h *History) Redo() error {
+	return h.apply(&h.redo, &h.undo, func(step historyStep) error {
+		for _, action := range step {
+			if err := action.redo(h.editor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Clear drops every recorded step.
+func (h *History) Clear() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.undo = nil
+	h.redo = nil
+}
+
+// Close stops recording the changes of the editor and drops every recorded step.
+func (h *History) Close() {
+	h.lock.Lock()
+	var unwatch = h.unwatch
+	h.unwatch = nil
+	h.lock.Unlock()
+
+	for _, unsubscribe := range unwatch {
+		unsubscribe()
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.undo, h.redo, h.cascaded = nil, nil, nil
+	h.nodes = make(map[NodeId]NodeInterface)
+	h.connections = make(map[ConnectionId]*Connection[ConnectionBase])
+}
+
+// apply pops a step from the `from` stack, runs it and moves it to the `to` stack when it succeeds.
+func (h *History) apply(from, to *[]historyStep, run func(historyStep) error) error {
+	h.lock.Lock()
+	if len(*from) == 0 {
+		h.lock.Unlock()
+		return errors.New("history has no step to apply")
+	}
+	var step = (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	h.applying = true
+	h.lock.Unlock()
+
+	err := run(step)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.applying = false
+	if err == nil {
+		*to = append(*to, step)
+	}
+	return err
+}
+
+func (h *History) handle(event Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var step = h.actions(event)
+	if h.applying || len(step) == 0 {
+		return
+	}
+
+	if h.group != nil {
+		*h.group = append(*h.group, step...)
+		return
+	}
+	h.push(step)
+}
+
+// actions updates the mirror with the event and returns the actions it records. The caller must hold the lock.
+func (h *History) actions(event Event) historyStep {
+	switch event.Type {
+	case "nodeCreated":
+		if node, ok := event.Data.(NodeInterface); ok {
+			h.nodes[node.Node().E.ID] = node
+			return historyStep{&nodeAction{node: node}}
+		}
+	case "nodeRemoved":
+		nodeID, _ := event.Data.(NodeId)
+		if node, ok := h.nodes[nodeID]; ok {
+			delete(h.nodes, nodeID)
+
+			var action = &nodeAction{node: node, removed: true}
+			h.cascaded = slices.DeleteFunc(h.cascaded, func(conn *Connection[ConnectionBase]) bool {
+				if conn.Source == nodeID || conn.Target == nodeID {
+					action.connections = append(action.connections, conn)
+					return true
+				}
+				return false
+			})
+			return historyStep{action}
+		}
+	case "connectionAdded":
+		if conn, ok := event.Data.(*Connection[ConnectionBase]); ok {
+			h.connections[conn.E.ID] = conn
+			return historyStep{&connectionAction{conn: conn}}
+		}
+	case "connectionRemoved":
+		connID, _ := event.Data.(ConnectionId)
+		if conn, ok := h.connections[connID]; ok {
+			delete(h.connections, connID)
+
+			// RemoveNode detaches the node before removing its connections
+			if h.detached(conn.Source) || h.detached(conn.Target) {
+				h.cascaded = append(h.cascaded, conn)
+				return nil
+			}
+			return historyStep{&connectionAction{conn: conn, removed: true}}
+		}
+	case "controlChanged":
+		if change, ok := event.Data.(ControlChange); ok && change.Control != nil {
+			return historyStep{&controlAction{control: change.Control, previous: change.Previous, value: change.Value}}
+		}
+	case "transactionCommitted":
+		events, _ := event.Data.([]Event)
+
+		var step historyStep
+		for _, event := range events {
+			step = append(step, h.actions(event)...)
+		}
+		return step
+	}
+
+	return nil
+}
+
+// detached reports whether a mirrored node has been detached from the editor. The caller must hold the lock.
+func (h *History) detached(nodeID NodeId) bool {
+	node, ok := h.nodes[nodeID]
+	return ok && node.Node().editor.Load() == nil
+}
+
+// push records a new step and drops the undone ones. The caller must hold the lock.
+func (h *History) push(step historyStep) {
+	if len(step) == 0 {
+		return
+	}
+
+	h.undo = append(h.undo, step)
+	if len(h.undo) > h.depth {
+		h.undo = slices.Delete(h.undo, 0, len(h.undo)-h.depth)
+	}
+	h.redo = nil
+}
+
+// nodeAction adds or removes a node, a removed node comes back with the connections removed with it.
+type nodeAction struct {
+	node        NodeInterface
+	connections []*Connection[ConnectionBase]
+	removed     bool
+}
+
+func (a *nodeAction) undo(editor *NodeEditor) error {
+	if a.removed {
+		return a.add(editor)
+	}
+	return a.remove(editor)
+}
+
+func (a *nodeAction) redo(editor *NodeEditor) error {
+	if a.removed {
+		return a.remove(editor)
+	}
+	return a.add(editor)
+}
+
+func (a *nodeAction) add(editor *NodeEditor) error {
+	if _, err := editor.AddNode(a.node); err != nil {
+		return err
+	}
+	for _, conn := range a.connections {
+		if err := editor.AddConnection(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *nodeAction) remove(editor *NodeEditor) error {
+	_, err := editor.RemoveNode(a.node.Node().E.ID)
+	return err
+}
+
+// connectionAction adds or removes a connection.
+type connectionAction struct {
+	conn    *Connection[ConnectionBase]
+	removed bool
+}
+
+func (a *connectionAction) undo(editor *NodeEditor) error {
+	if a.removed {
+		return editor.AddConnection(a.conn)
+	}
+	return editor.RemoveConnection(a.conn.E.ID)
+}
+
+func (a *connectionAction) redo(editor *NodeEditor) error {
+	if a.removed {
+		return editor.RemoveConnection(a.conn.E.ID)
+	}
+	return editor.AddConnection(a.conn)
+}
+
+// controlAction changes the value of a control.
+type controlAction struct {
+	control         *InputControl
+	previous, value any
+}
+
+func (a *controlAction) undo(*NodeEditor) error {
+	a.control.restore(a.previous)
+	return nil
+}
+
+func (a *controlAction) redo(*NodeEditor) error {
+	a.control.SetValue(a.value)
+	return nil
+}
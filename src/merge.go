@@ -0,0 +1,166 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// ImportStrategy decides what NodeEditor.Import does with a node or a connection whose id is already used by the editor.
+type ImportStrategy int
+
+const (
+	// ImportSkip keeps the editor's node or connection and drops the imported one. Imported connections
+	// pointing to a skipped node are connected to the editor's node
+	ImportSkip ImportStrategy = iota
+	// ImportOverwrite removes the editor's node, with its connections, or connection and adds the imported one
+	ImportOverwrite
+	// ImportRemap imports a copy of the node with a new id, or gives a new id to the connection, and rewires
+	// the imported connections. The input's nodes are left as they are
+	ImportRemap
+)
+
+// Import merges the nodes and connections of the input into the editor at once, nothing is merged when
+// a change fails. Once they're merged, subscribers receive the events of AddNode and AddConnection as
+// usual; call Import in a Transaction to receive them in a single `transactionCommitted` event. The
+// returned map gives the id every imported node has in the editor. Remapped nodes are copies of the
+// input's ones, see ImportRemap.
+func (e *NodeEditor) Import(input *JSONEditor, strategy ImportStrategy) (map[NodeId]NodeId, error) {
+	if input == nil {
+		return nil, errors.New("empty input given")
+	}
+
+	var ids map[NodeId]NodeId
+	err := e.transaction(func(tx *NodeEditor) error {
+		var err error
+		ids, err = tx.merge(input, strategy)
+		return err
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// merge adds the nodes and connections of the input one by one and stops at the first failing change.
+func (e *NodeEditor) merge(input *JSONEditor, strategy ImportStrategy) (map[NodeId]NodeId, error) {
+	var ids = make(map[NodeId]NodeId, len(input.Nodes))
+
+	var nodeIds = make([]NodeId, 0, len(input.Nodes))
+	for id := range input.Nodes {
+		nodeIds = append(nodeIds, id)
+	}
+	slices.Sort(nodeIds)
+
+	for _, id := range nodeIds {
+		var node = input.Nodes[id]
+		ids[id] = id
+
+		if existing, err := e.GetNode(id); err == nil {
+			if existing == node {
+				return ids, errors.New("node already belongs to the editor")
+			}
+
+			switch strategy {
+			case ImportSkip:
+				continue
+			case ImportOverwrite:
+				if _, err := e.RemoveNode(id); err != nil {
+					return ids, err
+				}
+			case ImportRemap:
+				clone, err := cloneNode(node)
+				if err != nil {
+					return ids, err
+				}
+				node = clone
+				ids[id] = clone.Node().E.ID
+			}
+		}
+
+		if _, err := e.AddNode(node); err != nil {
+			return ids, err
+		}
+	}
+
+	var connIds = make([]ConnectionId, 0, len(input.Connections))
+	for id := range input.Connections {
+		connIds = append(connIds, id)
+	}
+	slices.Sort(connIds)
+
+	for _, id := range connIds {
+		var conn = *input.Connections[id]
+		if newID, ok := ids[conn.Source]; ok {
+			conn.Source = newID
+		}
+		if newID, ok := ids[conn.Target]; ok {
+			conn.Target = newID
+		}
+
+		if _, err := e.GetConnection(id); err == nil {
+			switch strategy {
+			case ImportSkip:
+				continue
+			case ImportOverwrite:
+				if err := e.RemoveConnection(id); err != nil {
+					return ids, err
+				}
+			case ImportRemap:
+				conn.E.ID = ConnectionId(GetUID())
+			}
+		}
+
+		if err := e.AddConnection(&conn); err != nil {
+			return ids, err
+		}
+	}
+
+	return ids, nil
+}
+
+// cloneNode copies the node through its JSON form, the copy has new ids for itself, its ports and its
+// controls. It's created by DefaultNodeRegistry when the node has a type, it's a plain Node otherwise.
+func cloneNode(node NodeInterface) (NodeInterface, error) {
+	encoded, err := json.Marshal(jsonDocument{
+		Version: FormatVersion,
+		Nodes:   map[NodeId]*Node[NodeBase]{node.Node().E.ID: node.Node()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := NewJSONEditorData(encoded)
+	if err != nil {
+		return nil, err
+	}
+	remap(data)
+
+	decoded, err := NewJSONEditor(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, clone := range decoded.Nodes {
+		return clone, nil
+	}
+	return nil, errors.New("node could not be copied")
+}
+
+// Clear removes every connection and then every node of the editor, publishing the same events as
+// RemoveConnection and RemoveNode. It stops at the first removal cancelled by a subscriber.
+func (e *NodeEditor) Clear() error {
+	for _, conn := range e.GetConnections() {
+		if err := e.RemoveConnection(conn.E.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range e.GetNodes() {
+		if _, err := e.RemoveNode(node.Node().E.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
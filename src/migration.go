@@ -0,0 +1,167 @@
+package src
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FormatVersion is the version of the documents written by NodeEditor.Deserialize and NodeEditor.Copy.
+// Documents without a version are version 0, written before the format has been versioned. The maximum
+// version of the document schema is raised along with it.
+const FormatVersion = 1
+
+// Migration upgrades a decoded document from the version it's registered for to the next one.
+// The registry sets the new version once it returns.
+type Migration func(document map[string]any) error
+
+// EntryMigration upgrades the nodes and the connections of a document one at a time, from the version
+// it's registered for to the next one. Unlike a Migration, it lets DecodeJSONEditor migrate a document
+// while it's streamed. A nil func leaves its entries as they are, entries which aren't objects are skipped.
+type EntryMigration struct {
+	Node       func(id string, node map[string]any) error
+	Connection func(id string, connection map[string]any) error
+}
+
+// MigrationRegistry holds the migrations upgrading older documents to FormatVersion.
+type MigrationRegistry struct {
+	lock    sync.RWMutex
+	steps   map[int]Migration
+	entries map[int]EntryMigration
+}
+
+// DefaultMigrations is used by NewJSONEditorData, it has every migration of the built-in formats.
+var DefaultMigrations = NewMigrationRegistry()
+
+func init() {
+	// version 1 only adds the `version` field
+	DefaultMigrations.RegisterEntries(0, EntryMigration{})
+}
+
+func NewMigrationRegistry() *MigrationRegistry {
+	return &MigrationRegistry{
+		steps:   make(map[int]Migration),
+		entries: make(map[int]EntryMigration),
+	}
+}
+
+// Register adds the migration upgrading documents of the version `from` to the version `from + 1`,
+// it replaces the one registered with RegisterEntries. DecodeJSONEditor has to hold the whole document
+// to run it.
+func (r *MigrationRegistry) Register(from int, migration Migration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.entries, from)
+	r.steps[from] = migration
+}
+
+// RegisterEntries adds the migration upgrading the entries of documents of the version `from` to the
+// version `from + 1`, it replaces the one registered with Register.
+func (r *MigrationRegistry) RegisterEntries(from int, migration EntryMigration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.steps, from)
+	r.entries[from] = migration
+}
+
+// entrySteps returns the entry migrations upgrading documents of the version to FormatVersion, ok is
+// false when one of the steps has to migrate the whole document or is missing.
+func (r *MigrationRegistry) entrySteps(version int) (steps []EntryMigration, ok bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for ; version < FormatVersion; version++ {
+		step, ok := r.entries[version]
+		if !ok {
+			return nil, false
+		}
+		steps = append(steps, step)
+	}
+	return steps, true
+}
+
+// migrateEntry runs the steps on a single node or connection of the document, section tells which one.
+func migrateEntry(steps []EntryMigration, section, id string, value any) error {
+	entry, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for _, step := range steps {
+		var err error
+		switch {
+		case section == "nodes" && step.Node != nil:
+			err = step.Node(id, entry)
+		case section == "connections" && step.Connection != nil:
+			err = step.Connection(id, entry)
+		}
+		if err != nil {
+			return fmt.Errorf("migrating %s: %w", jsonPointer("", section, id), err)
+		}
+	}
+	return nil
+}
+
+// Migrate upgrades the document in place, one version after another, up to FormatVersion.
+func (r *MigrationRegistry) Migrate(document map[string]any) error {
+	version, err := documentVersion(document)
+	if err != nil {
+		return err
+	}
+	if version > FormatVersion {
+		return fmt.Errorf("document version %d is newer than the supported version %d", version, FormatVersion)
+	}
+
+	for ; version < FormatVersion; version++ {
+		r.lock.RLock()
+		migration, ok := r.steps[version]
+		entries, entriesOk := r.entries[version]
+		r.lock.RUnlock()
+
+		if !ok && entriesOk {
+			migration = entries.document
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("no migration from document version %d", version)
+		}
+		if err := migration(document); err != nil {
+			return fmt.Errorf("migrating document version %d: %w", version, err)
+		}
+		document["version"] = version + 1
+	}
+
+	return nil
+}
+
+// document runs the migration on every node and connection of the document.
+func (m EntryMigration) document(document map[string]any) error {
+	for _, section := range []string{"nodes", "connections"} {
+		entries, _ := document[section].(map[string]any)
+		for _, id := range sortedKeys(entries) {
+			if err := migrateEntry([]EntryMigration{m}, section, id, entries[id]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// documentVersion returns the `version` of a decoded document, 0 when it's missing.
+func documentVersion(document map[string]any) (int, error) {
+	value, ok := document["version"]
+	if !ok || value == nil {
+		return 0, nil
+	}
+
+	var d decoder
+	version := d.int(document, "version", "")
+	if err := d.err(); err != nil {
+		return 0, err
+	}
+	if version < 0 {
+		return 0, &DecodeErrors{Errors: []*DecodeError{{Pointer: "/version", Message: "expected a non-negative version"}}}
+	}
+	return version, nil
+}
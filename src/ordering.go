@@ -0,0 +1,107 @@
+package src
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Ordering decides the order NodeEditor returns its nodes and connections in, and so the order
+// the engines evaluate them in.
+type Ordering int
+
+const (
+	// OrderingNone doesn't sort anything, the order changes from call to call
+	OrderingNone Ordering = iota
+	// OrderingInsertion sorts nodes and connections in the order they have been added to the editor
+	OrderingInsertion
+	// OrderingIndex sorts connections by the Index of their target input port, then of their source
+	// output port, then in insertion order. Nodes are sorted in insertion order
+	OrderingIndex
+)
+
+// SetOrdering changes the ordering of the editor accessors. Graphs built by Serialize get their
+// insertion order from the sorted node and connection ids.
+func (e *NodeEditor) SetOrdering(ordering Ordering) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.ordering = ordering
+}
+
+// trackNode gives the next insertion sequence to the node. The caller must hold the lock.
+func (e *NodeEditor) trackNode(nodeID NodeId) {
+	e.sequence++
+	e.nodeSequences[nodeID] = e.sequence
+}
+
+// trackConnection gives the next insertion sequence to the connection. The caller must hold the lock.
+func (e *NodeEditor) trackConnection(connID ConnectionId) {
+	e.sequence++
+	e.connectionSequences[connID] = e.sequence
+}
+
+// sortNodes sorts the nodes in place according to the ordering. The caller must hold the lock.
+func (e *NodeEditor) sortNodes(nodes []NodeInterface) {
+	if e.ordering == OrderingNone {
+		return
+	}
+
+	slices.SortFunc(nodes, func(a, b NodeInterface) int {
+		return cmp.Compare(e.nodeSequences[a.Node().E.ID], e.nodeSequences[b.Node().E.ID])
+	})
+}
+
+// sortNodeIds sorts the ids in place according to the ordering, by id when there's none.
+// The caller must hold the lock.
+func (e *NodeEditor) sortNodeIds(ids []NodeId) {
+	if e.ordering == OrderingNone {
+		slices.Sort(ids)
+		return
+	}
+
+	slices.SortFunc(ids, func(a, b NodeId) int {
+		return cmp.Compare(e.nodeSequences[a], e.nodeSequences[b])
+	})
+}
+
+// sortConnections sorts the connections in place according to the ordering. The caller must hold the lock.
+func (e *NodeEditor) sortConnections(conns []*Connection[ConnectionBase]) {
+	if e.ordering == OrderingNone {
+		return
+	}
+
+	slices.SortFunc(conns, func(a, b *Connection[ConnectionBase]) int {
+		if e.ordering == OrderingIndex {
+			if c := cmp.Compare(e.portIndex(a.Target, string(a.TargetInput), true), e.portIndex(b.Target, string(b.TargetInput), true)); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(e.portIndex(a.Source, string(a.SourceOutput), false), e.portIndex(b.Source, string(b.SourceOutput), false)); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(e.connectionSequences[a.E.ID], e.connectionSequences[b.E.ID])
+	})
+}
+
+// portIndex returns the Index of an input or output port of a node, 0 if there's no such port.
+// The caller must hold the lock.
+func (e *NodeEditor) portIndex(nodeID NodeId, key string, input bool) int {
+	node, ok := e.nodes[nodeID]
+	if !ok {
+		return 0
+	}
+
+	var ports = node.Node().Outputs
+	if input {
+		ports = node.Node().Inputs
+	}
+
+	value, ok := ports.Get(key)
+	if !ok {
+		return 0
+	}
+	if port, ok := portOf(value); ok {
+		return port.Index
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package src
+
+import "slices"
+
+// ConnectionPolicy decides what AddConnection does when a port which doesn't accept multiple
+// connections is already connected.
+type ConnectionPolicy int
+
+const (
+	// ConnectionPolicyReject rejects the new connection with a *PortOccupiedError
+	ConnectionPolicyReject ConnectionPolicy = iota
+	// ConnectionPolicyReplace removes the existing connections of the port, publishing `connectionRemoved`
+	// for each of them, and adds the new one. Call AddConnection in a Transaction to publish the changes
+	// at once and undo them in a single step
+	ConnectionPolicyReplace
+)
+
+// SetConnectionPolicy changes how the editor handles connections to ports which are already connected
+// and don't accept multiple connections. Default is ConnectionPolicyReject.
+func (e *NodeEditor) SetConnectionPolicy(policy ConnectionPolicy) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.policy = policy
+}
+
+// occupiedPorts returns the ports of the connection which don't accept multiple connections and are
+// already connected, the output first. The caller must hold the lock.
+func (e *NodeEditor) occupiedPorts(conn *Connection[ConnectionBase], source, target *Node[NodeBase]) []*PortOccupiedError {
+	var occupied []*PortOccupiedError
+
+	if output, ok := source.Outputs.Get(string(conn.SourceOutput)); ok && !acceptsMultiple(output) {
+		var port = &PortOccupiedError{NodeId: conn.Source, Key: string(conn.SourceOutput), Side: "output"}
+		for _, existing := range e.outgoing[conn.Source] {
+			if existing.SourceOutput == conn.SourceOutput {
+				port.Connections = append(port.Connections, existing.E.ID)
+			}
+		}
+		if len(port.Connections) > 0 {
+			occupied = append(occupied, port)
+		}
+	}
+
+	if input, ok := target.Inputs.Get(string(conn.TargetInput)); ok && !acceptsMultiple(input) {
+		var port = &PortOccupiedError{NodeId: conn.Target, Key: string(conn.TargetInput), Side: "input"}
+		for _, existing := range e.incoming[conn.Target] {
+			if existing.TargetInput == conn.TargetInput {
+				port.Connections = append(port.Connections, existing.E.ID)
+			}
+		}
+		if len(port.Connections) > 0 {
+			occupied = append(occupied, port)
+		}
+	}
+
+	for _, port := range occupied {
+		slices.Sort(port.Connections)
+	}
+	return occupied
+}
+
+// acceptsMultiple reports whether an input or output value accepts multiple connections.
+// Values without a recognizable port are not restricted.
+func acceptsMultiple(value any) bool {
+	port, ok := portOf(value)
+	return !ok || port.MultipleConnections
+}
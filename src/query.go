@@ -0,0 +1,144 @@
+package src
+
+import (
+	"errors"
+	"github.com/ashkan90/auto-core/utils"
+	"slices"
+)
+
+// Predecessors returns the distinct nodes connected to the inputs of the node.
+func (e *NodeEditor) Predecessors(nodeID NodeId) []NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return slices.Clone(e.sources()[nodeID])
+}
+
+// Successors returns the distinct nodes connected to the outputs of the node.
+func (e *NodeEditor) Successors(nodeID NodeId) []NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var _, edges = e.adjacency()
+	return slices.Compact(edges[nodeID])
+}
+
+// Upstream returns every node the node depends on, directly or through other nodes, nearest first.
+// The node itself is returned only when it's a part of a cycle.
+func (e *NodeEditor) Upstream(nodeID NodeId) []NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return closure(nodeID, e.sources())
+}
+
+// Downstream returns every node depending on the node, directly or through other nodes, nearest first.
+// The node itself is returned only when it's a part of a cycle.
+func (e *NodeEditor) Downstream(nodeID NodeId) []NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var _, edges = e.adjacency()
+	return closure(nodeID, edges)
+}
+
+// AllPaths returns every path going from `from` to `to` which doesn't visit a node twice, shortest first.
+func (e *NodeEditor) AllPaths(from, to NodeId) [][]NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var _, edges = e.adjacency()
+	if _, ok := e.nodes[from]; !ok {
+		return nil
+	}
+
+	var paths [][]NodeId
+	var path []NodeId
+	var visited = make(map[NodeId]bool)
+
+	var visit func(id NodeId)
+	visit = func(id NodeId) {
+		path = append(path, id)
+		defer func() { path = path[:len(path)-1] }()
+
+		if id == to {
+			paths = append(paths, slices.Clone(path))
+			return
+		}
+
+		visited[id] = true
+		defer delete(visited, id)
+
+		for _, target := range slices.Compact(slices.Clone(edges[id])) {
+			if !visited[target] {
+				visit(target)
+			}
+		}
+	}
+	visit(from)
+
+	slices.SortStableFunc(paths, func(a, b []NodeId) int {
+		return len(a) - len(b)
+	})
+	return paths
+}
+
+// GetNodeByPort returns the node owning the input or output whose port has the given id, and the key of that port.
+func (e *NodeEditor) GetNodeByPort(portID PortId) (NodeInterface, string, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	for _, node := range e.sortedNodes() {
+		for _, ports := range []*utils.SyncMap{node.Node().Inputs, node.Node().Outputs} {
+			var found string
+			ports.Range(func(key, value any) bool {
+				if port, ok := portOf(value); ok && port.Id == portID {
+					found = key.(string)
+					return false
+				}
+				return true
+			})
+			if found != "" {
+				return node, found, nil
+			}
+		}
+	}
+
+	return nil, "", errors.New("port does not exist")
+}
+
+// sources returns the distinct source nodes of every node, sorted according to the ordering.
+// The caller must hold the lock.
+func (e *NodeEditor) sources() map[NodeId][]NodeId {
+	var ids, edges = e.adjacency()
+	var sources = make(map[NodeId][]NodeId)
+
+	// ids are already sorted, so the sources come out sorted too
+	for _, id := range ids {
+		for _, target := range slices.Compact(edges[id]) {
+			sources[target] = append(sources[target], id)
+		}
+	}
+	return sources
+}
+
+// closure walks the edges breadth-first from the node and returns every node it reaches.
+func closure(nodeID NodeId, edges map[NodeId][]NodeId) []NodeId {
+	var reached []NodeId
+	var seen = make(map[NodeId]bool)
+	var queue = []NodeId{nodeID}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, next := range edges[id] {
+			if !seen[next] {
+				seen[next] = true
+				reached = append(reached, next)
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
@@ -0,0 +1,122 @@
+package src
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// NodeFactory creates a node of a registered type with its ports and controls.
+type NodeFactory func() NodeInterface
+
+// NodeRegistry maps type names to the factories rebuilding the nodes of that type when a graph is decoded.
+type NodeRegistry struct {
+	lock      sync.RWMutex
+	factories map[string]NodeFactory
+	// names type name of the Go type of the nodes every factory creates
+	names map[reflect.Type]string
+}
+
+// DefaultNodeRegistry is used by NewJSONEditor.
+var DefaultNodeRegistry = NewNodeRegistry()
+
+func NewNodeRegistry() *NodeRegistry {
+	return &NodeRegistry{
+		factories: make(map[string]NodeFactory),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// Register adds the factory of a node type, replacing the previous one registered with the same name.
+// The Go type of the nodes it creates is bound to the name too, so the nodes built with their own
+// constructor get their Type once they're added to an editor.
+func (r *NodeRegistry) Register(name string, factory NodeFactory) {
+	var goType = reflect.TypeOf(factory())
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.factories[name] = factory
+	r.names[goType] = name
+}
+
+// Types returns the registered type names.
+func (r *NodeRegistry) Types() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var names = make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Create creates a node of the type and sets its Type, so it's restored as the same type once decoded.
+func (r *NodeRegistry) Create(name string) (NodeInterface, error) {
+	r.lock.RLock()
+	factory, ok := r.factories[name]
+	r.lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("node type %q is not registered", name)
+	}
+
+	var node = factory()
+	node.Node().E.Type = name
+	return node, nil
+}
+
+// typeOf returns the type name the Go type of the node has been registered with.
+func (r *NodeRegistry) typeOf(node NodeInterface) (string, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	name, ok := r.names[reflect.TypeOf(node)]
+	return name, ok
+}
+
+// restore creates the node of the decoded node's type and moves the decoded state into it: the id,
+// the ports and the selection replace the factory's ones, the ids and values of the decoded input
+// controls are given to the factory's controls so their callbacks are kept. Nodes without a type
+// are returned as they are.
+func (r *NodeRegistry) restore(decoded *Node[NodeBase]) (NodeInterface, error) {
+	if decoded.E.Type == "" {
+		return decoded, nil
+	}
+
+	node, err := r.Create(decoded.E.Type)
+	if err != nil {
+		return nil, fmt.Errorf("node %s: %w", decoded.E.ID, err)
+	}
+
+	var n = node.Node()
+	n.E = decoded.E
+	n.Selected = decoded.Selected
+
+	decoded.Inputs.Range(func(key, value any) bool {
+		n.Inputs.Add(key.(string), value)
+		return true
+	})
+	decoded.Outputs.Range(func(key, value any) bool {
+		n.Outputs.Add(key.(string), value)
+		return true
+	})
+	decoded.Controls.Range(func(key, value any) bool {
+		control, decodedOk := value.(*InputControl)
+		current, _ := n.Controls.Get(key.(string))
+		if target, ok := current.(*InputControl); ok && decodedOk {
+			target.Control = control.Control
+			target.Readonly = control.Readonly
+			target.Value = control.Value
+			return true
+		}
+
+		n.AddControl(key.(string), value.(ControlInterface))
+		return true
+	})
+
+	return node, nil
+}
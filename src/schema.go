@@ -0,0 +1,205 @@
+package src
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed schema/document.schema.json
+var documentSchema []byte
+
+var parsedDocumentSchema = sync.OnceValue(func() map[string]any {
+	var schema map[string]any
+	if err := json.Unmarshal(documentSchema, &schema); err != nil {
+		panic(fmt.Sprintf("invalid document schema: %v", err))
+	}
+	return schema
+})
+
+// DocumentSchema returns the JSON Schema (draft 2020-12) of the documents written by NodeEditor.Deserialize
+// and read by NewJSONEditorData, which covers JSONEditorData, JSONEditorNode, JSONEditorConnection and
+// their ports, controls and sockets.
+func DocumentSchema() []byte {
+	return slices.Clone(documentSchema)
+}
+
+// ValidateDocument checks the raw document against DocumentSchema before it's decoded. Every problem is
+// reported in a *DecodeErrors, with a JSON pointer to the offending field.
+func ValidateDocument(input []byte) error {
+	var document any
+	if err := json.Unmarshal(input, &document); err != nil {
+		return err
+	}
+
+	var root = parsedDocumentSchema()
+	var v = schemaValidator{root: root}
+	v.validate(root, document, "")
+	return v.err()
+}
+
+// schemaValidator implements the JSON Schema keywords DocumentSchema uses: `$ref` to `$defs`, `anyOf`,
+// `type`, `minimum`, `maximum`, `minLength`, `required`, `properties`, `additionalProperties` and `items`.
+type schemaValidator struct {
+	decoder
+	root map[string]any
+}
+
+func (v *schemaValidator) validate(schema map[string]any, value any, pointer string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		v.validate(v.resolve(ref), value, pointer)
+	}
+
+	if branches, ok := schema["anyOf"].([]any); ok {
+		v.anyOf(branches, value, pointer)
+	}
+
+	if expected, ok := schema["type"]; ok && !matchesType(expected, value) {
+		v.fail(pointer, "expected %s, got %s", typeNames(expected), jsonType(value))
+		return
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && value < minimum {
+			v.fail(pointer, "expected a number not less than %v, got %v", minimum, value)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && value > maximum {
+			v.fail(pointer, "expected a number not greater than %v, got %v", maximum, value)
+		}
+	case string:
+		if minLength, ok := schema["minLength"].(float64); ok && float64(utf8.RuneCountInString(value)) < minLength {
+			v.fail(pointer, "expected a string of at least %v character(s)", minLength)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				v.validate(items, item, jsonPointer(pointer, fmt.Sprint(i)))
+			}
+		}
+	case map[string]any:
+		v.object(schema, value, pointer)
+	}
+}
+
+func (v *schemaValidator) object(schema map[string]any, value map[string]any, pointer string) {
+	required, _ := schema["required"].([]any)
+	for _, key := range required {
+		if _, ok := value[key.(string)]; !ok {
+			v.fail(jsonPointer(pointer, key.(string)), "is required")
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	for _, key := range sortedKeys(value) {
+		if property, ok := properties[key].(map[string]any); ok {
+			v.validate(property, value[key], jsonPointer(pointer, key))
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case map[string]any:
+			v.validate(additional, value[key], jsonPointer(pointer, key))
+		case bool:
+			if !additional {
+				v.fail(jsonPointer(pointer, key), "is not allowed")
+			}
+		}
+	}
+}
+
+// anyOf accepts the value when a branch matches, and otherwise reports the problems of the closest
+// branch: one whose type matches the value, with the fewest problems.
+func (v *schemaValidator) anyOf(branches []any, value any, pointer string) {
+	var closest []*DecodeError
+	var closestTyped bool
+	for i, branch := range branches {
+		var schema = branch.(map[string]any)
+		var attempt = schemaValidator{root: v.root}
+		attempt.validate(schema, value, pointer)
+		if len(attempt.errors) == 0 {
+			return
+		}
+
+		var typed = v.admits(schema, value)
+		if i == 0 || (typed && !closestTyped) || (typed == closestTyped && len(attempt.errors) < len(closest)) {
+			closest, closestTyped = attempt.errors, typed
+		}
+	}
+	v.errors = append(v.errors, closest...)
+}
+
+// admits reports whether the value has the type of the schema, following its reference.
+func (v *schemaValidator) admits(schema map[string]any, value any) bool {
+	if ref, ok := schema["$ref"].(string); ok {
+		return v.admits(v.resolve(ref), value)
+	}
+	if expected, ok := schema["type"]; ok {
+		return matchesType(expected, value)
+	}
+	return true
+}
+
+// resolve returns the schema a local `#/...` reference points to.
+func (v *schemaValidator) resolve(ref string) map[string]any {
+	var schema any = v.root
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, _ := schema.(map[string]any)
+		schema = object[strings.NewReplacer("~1", "/", "~0", "~").Replace(key)]
+	}
+
+	resolved, ok := schema.(map[string]any)
+	if !ok {
+		panic(fmt.Sprintf("document schema has an unknown reference %s", ref))
+	}
+	return resolved
+}
+
+// matchesType reports whether the value has the JSON Schema type, or one of the types.
+func matchesType(expected any, value any) bool {
+	if types, ok := expected.([]any); ok {
+		return slices.ContainsFunc(types, func(name any) bool {
+			return matchesType(name, value)
+		})
+	}
+
+	switch expected {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func typeNames(expected any) string {
+	if types, ok := expected.([]any); ok {
+		var names = make([]string, 0, len(types))
+		for _, name := range types {
+			names = append(names, fmt.Sprint(name))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(expected)
+}
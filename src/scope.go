@@ -1,7 +1,10 @@
 package src
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"sync"
 )
 
@@ -19,28 +22,36 @@ type EventHandler func(Event)
 
 // EventBus , event'leri yöneten ve event dinleyicilerini (subscribers) tutan yapıdır.
 type EventBus struct {
-	listeners map[EventType][]EventHandler
+	listeners map[EventType][]subscription
 	lock      sync.Mutex
+	// sequence son verilen abonelik numarası
+	sequence uint64
+}
+
+// subscription , bir işleyiciyi aboneliğin kaldırılabilmesi için numarasıyla tutar.
+type subscription struct {
+	id      uint64
+	handler EventHandler
 }
 
 // NewEventBus , yeni bir EventBus örneği oluşturur.
 func NewEventBus() *EventBus {
 	return &EventBus{
-		listeners: make(map[EventType][]EventHandler),
+		listeners: make(map[EventType][]subscription),
 	}
 }
 
 // Publish , bir event'i yayınlar ve ilgili tüm işleyicileri tetikler.
 func (bus *EventBus) Publish(event Event) {
 	bus.lock.Lock()
-	handlers := bus.listeners[event.Type]
+	subscriptions := bus.listeners[event.Type]
 	bus.lock.Unlock()
 
 	//wg := sync.WaitGroup{}
 
-	for _, handler := range handlers {
+	for _, subscription := range subscriptions {
 		log.Println("[EventBus] an event started to handle", event)
-		handler(event)
+		subscription.handler(event)
 		// Her bir handler'ı kendi goroutine'inde çalıştırarak asenkron işlem sağlanabilir.
 		//wg.Add(1)
 		//go func(wg *sync.WaitGroup, handler EventHandler) {
@@ -53,12 +64,74 @@ func (bus *EventBus) Publish(event Event) {
 	//wg.Wait()
 }
 
-// Subscribe , belirli bir event türüne bir işleyici (handler) ekler.
-func (bus *EventBus) Subscribe(eventType EventType, handler EventHandler) {
+// Subscribe , belirli bir event türüne bir işleyici (handler) ekler. Dönen fonksiyon işleyiciyi
+// kaldırır, birden fazla çağrılabilir.
+func (bus *EventBus) Subscribe(eventType EventType, handler EventHandler) func() {
 	bus.lock.Lock()
 	defer bus.lock.Unlock()
 
 	log.Println("[EventBus] an event listener has been registered", eventType)
 
-	bus.listeners[eventType] = append(bus.listeners[eventType], handler)
+	bus.sequence++
+	var id = bus.sequence
+	bus.listeners[eventType] = append(bus.listeners[eventType], subscription{id: id, handler: handler})
+
+	return func() {
+		bus.unsubscribe(eventType, id)
+	}
+}
+
+// unsubscribe , işleyiciyi kaldırır. Yayınlanmakta olan event'lerin listesi değişmesin diye liste kopyalanır.
+func (bus *EventBus) unsubscribe(eventType EventType, id uint64) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	var subscriptions = slices.DeleteFunc(slices.Clone(bus.listeners[eventType]), func(s subscription) bool {
+		return s.id == id
+	})
+	if len(subscriptions) == 0 {
+		delete(bus.listeners, eventType)
+		return
+	}
+	bus.listeners[eventType] = subscriptions
+}
+
+// Signal , editörün bir değişikliği yapmadan önce yayınladığı event'lerin (nodeCreate, nodeRemove,
+// connectionCreate, connectionRemove) verisidir. İşleyiciler Data'yı değiştirebilir ya da Cancel ile
+// değişikliği engelleyebilir. Editör bu event'leri kilidini almadan yayınlar, işleyiciler editörü kullanabilir;
+// bu yüzden değişiklik kilit alındıktan sonra yeniden doğrulanır. Bir düğüm ya da port ile birlikte veya
+// ConnectionPolicyReplace ile kaldırılan her bağlantı için de connectionRemove yayınlanır, biri engellenirse
+// değişikliğin tamamı yapılmaz.
+type Signal struct {
+	Data any
+	err  error
+}
+
+// Cancel , değişikliği engeller. err nil ise genel bir hata kullanılır.
+func (s *Signal) Cancel(err error) {
+	if err == nil {
+		err = errors.New("cancelled by a subscriber")
+	}
+	s.err = err
+}
+
+// Err , değişiklik engellendiyse Cancel'a verilen hatayı döndürür.
+func (s *Signal) Err() error {
+	return s.err
+}
+
+// emitSignal , sinyali yayınlar ve işleyicilerin bıraktığı veriyi döndürür.
+func emitSignal[T any](bus *EventBus, eventType EventType, data T) (T, error) {
+	var signal = &Signal{Data: data}
+	bus.Publish(Event{Type: eventType, Data: signal})
+
+	if signal.err != nil {
+		return data, &SignalCancelledError{Type: eventType, Err: signal.err}
+	}
+
+	result, ok := signal.Data.(T)
+	if !ok {
+		return data, fmt.Errorf("%s: subscriber has set data of type %T, expected %T", eventType, signal.Data, data)
+	}
+	return result, nil
 }
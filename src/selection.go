@@ -0,0 +1,195 @@
+package src
+
+import (
+	"errors"
+)
+
+// Select selects the nodes and publishes `nodeSelected` for every node which wasn't selected yet.
+// Nothing is selected when one of the nodes doesn't exist. The selection is kept in Node.Selected,
+// it isn't a part of transactions nor of the history.
+func (e *NodeEditor) Select(nodeIDs ...NodeId) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	nodes, err := e.lookup(nodeIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		e.setSelected(node, true)
+	}
+	return nil
+}
+
+// Unselect deselects the nodes and publishes `nodeUnselected` for every node which was selected.
+// Nothing is deselected when one of the nodes doesn't exist.
+func (e *NodeEditor) Unselect(nodeIDs ...NodeId) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	nodes, err := e.lookup(nodeIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		e.setSelected(node, false)
+	}
+	return nil
+}
+
+// ToggleSelection selects the node when it isn't selected and deselects it otherwise.
+func (e *NodeEditor) ToggleSelection(nodeID NodeId) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	node, exists := e.nodes[nodeID]
+	if !exists {
+		return errors.New("node does not exist")
+	}
+
+	e.setSelected(node, !isSelected(node))
+	return nil
+}
+
+// SelectWhere selects every node the predicate returns true for, the others keep their state.
+func (e *NodeEditor) SelectWhere(predicate func(node NodeInterface) bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, node := range e.sortedNodes() {
+		if predicate(node) {
+			e.setSelected(node, true)
+		}
+	}
+}
+
+// SelectComponent selects every node connected to the node, whatever the direction of the connections.
+func (e *NodeEditor) SelectComponent(nodeID NodeId) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	node, exists := e.nodes[nodeID]
+	if !exists {
+		return errors.New("node does not exist")
+	}
+
+	var _, edges = e.adjacency()
+	var neighbours = make(map[NodeId][]NodeId)
+	for source, targets := range edges {
+		for _, target := range targets {
+			neighbours[source] = append(neighbours[source], target)
+			neighbours[target] = append(neighbours[target], source)
+		}
+	}
+
+	e.setSelected(node, true)
+	for _, id := range closure(nodeID, neighbours) {
+		e.setSelected(e.nodes[id], true)
+	}
+	return nil
+}
+
+// ClearSelection deselects every node.
+func (e *NodeEditor) ClearSelection() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, node := range e.sortedNodes() {
+		e.setSelected(node, false)
+	}
+}
+
+// Selected returns the ids of the selected nodes.
+func (e *NodeEditor) Selected() []NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var ids []NodeId
+	for _, node := range e.sortedNodes() {
+		if isSelected(node) {
+			ids = append(ids, node.Node().E.ID)
+		}
+	}
+	return ids
+}
+
+// RemoveSelected removes the selected nodes with their connections. It stops at the first removal
+// cancelled by a subscriber.
+func (e *NodeEditor) RemoveSelected() error {
+	for _, nodeID := range e.Selected() {
+		if _, err := e.RemoveNode(nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopySelected encodes the selected nodes into a clipboard, see Copy.
+func (e *NodeEditor) CopySelected(opt *CopyOptions) ([]byte, error) {
+	return e.Copy(e.Selected(), opt)
+}
+
+// DuplicateSelected duplicates the selected nodes and selects the copies instead, see Duplicate.
+func (e *NodeEditor) DuplicateSelected(opt *CopyOptions) (map[NodeId]NodeId, error) {
+	var selected = e.Selected()
+
+	ids, err := e.Duplicate(selected, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var copies = make([]NodeId, 0, len(ids))
+	for _, id := range selected {
+		copies = append(copies, ids[id])
+	}
+
+	if err := e.Unselect(selected...); err != nil {
+		return ids, err
+	}
+	return ids, e.Select(copies...)
+}
+
+// lookup returns the nodes with the given ids, or an error if one of them doesn't exist. The caller must hold the lock.
+func (e *NodeEditor) lookup(nodeIDs []NodeId) ([]NodeInterface, error) {
+	var nodes = make([]NodeInterface, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		node, exists := e.nodes[nodeID]
+		if !exists {
+			return nil, errors.New("node does not exist")
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// sortedNodes returns the nodes sorted according to the ordering. The caller must hold the lock.
+func (e *NodeEditor) sortedNodes() []NodeInterface {
+	var nodes = make([]NodeInterface, 0, len(e.nodes))
+	for _, node := range e.nodes {
+		nodes = append(nodes, node)
+	}
+	e.sortNodes(nodes)
+	return nodes
+}
+
+// setSelected changes the selection state of the node and publishes the matching event when it changes.
+// The caller must hold the lock.
+func (e *NodeEditor) setSelected(node NodeInterface, selected bool) {
+	if isSelected(node) == selected {
+		return
+	}
+
+	node.Node().Selected = ToPtr(selected)
+	if selected {
+		e.eventBus.Publish(Event{Type: "nodeSelected", Data: node.Node().E.ID})
+		return
+	}
+	e.eventBus.Publish(Event{Type: "nodeUnselected", Data: node.Node().E.ID})
+}
+
+func isSelected(node NodeInterface) bool {
+	var selected = node.Node().Selected
+	return selected != nil && *selected
+}
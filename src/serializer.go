@@ -3,8 +3,6 @@ package src
 import (
 	"encoding/json"
 	"errors"
-	"github.com/ashkan90/auto-core/utils"
-	"sync"
 )
 
 type JSONEditor struct {
@@ -13,10 +11,19 @@ type JSONEditor struct {
 }
 
 type JSONEditorData struct {
+	// Version format version of the document, documents are migrated to FormatVersion when they're decoded
+	Version     int                                    `json:"version"`
 	Nodes       map[NodeId]*JSONEditorNode             `json:"nodes"`
 	Connections map[ConnectionId]*JSONEditorConnection `json:"connections"`
 }
 
+// jsonDocument is the layout NodeEditor.Copy writes, the same as NodeEditor.Encode with the version first.
+type jsonDocument struct {
+	Version     int                                          `json:"version"`
+	Connections map[ConnectionId]*Connection[ConnectionBase] `json:"connections"`
+	Nodes       map[NodeId]*Node[NodeBase]                   `json:"nodes"`
+}
+
 type JSONEditorNode struct {
 	Base     JSONEditorNodeBase `json:"base"`
 	Inputs   map[string]any     `json:"inputs"`
@@ -26,7 +33,8 @@ type JSONEditorNode struct {
 }
 
 type JSONEditorNodeBase struct {
-	Id NodeId `json:"id"`
+	Id   NodeId `json:"id"`
+	Type string `json:"type,omitempty"`
 }
 
 type JSONEditorConnection struct {
@@ -43,138 +51,69 @@ type JSONEditorConnectionBase struct {
 	Target string `json:"target"`
 }
 
+// NewJSONEditor rebuilds the nodes and connections of the data, nodes having a type are created by DefaultNodeRegistry.
 func NewJSONEditor(jsonData *JSONEditorData) (*JSONEditor, error) {
+	return NewJSONEditorWithRegistry(jsonData, DefaultNodeRegistry)
+}
+
+// NewJSONEditorWithRegistry is like NewJSONEditor but creates the nodes having a type with the given registry.
+// Decoding never panics on malformed data, every missing or mistyped field is reported in a *DecodeErrors.
+func NewJSONEditorWithRegistry(jsonData *JSONEditorData, registry *NodeRegistry) (*JSONEditor, error) {
 	if jsonData == nil {
 		return nil, errors.New("empty input given")
 	}
 
+	var d decoder
 	var editor = &JSONEditor{
 		Nodes:       make(map[NodeId]NodeInterface),
 		Connections: make(map[ConnectionId]*Connection[ConnectionBase]),
 	}
 
-	for id, node := range jsonData.Nodes {
-		var inlineNode = &Node[NodeBase]{
-			E:        NodeBase{ID: id},
-			Inputs:   utils.NewSyncMap(),
-			Outputs:  utils.NewSyncMap(),
-			Controls: utils.NewSyncMap(),
-			Selected: node.Selected,
-			mu:       &sync.Mutex{},
-		}
-
-		for inputId, inputValue := range node.Inputs {
-			inputValueCpy := inputValue.(map[string]any)
-			if inputValueCpy == nil {
-				continue
-			}
-			inputValuePort := inputValueCpy["port"].(map[string]any)
-			inputValueControl := inputValueCpy["control"].(map[string]any)
-
-			inlineNode.Inputs.Add(inputId, &Input[Socket]{
-				Port: &Port[Socket]{
-					Id:                  PortId(inputValuePort["id"].(string)),
-					Label:               inputValuePort["label"].(string),
-					Index:               int(inputValuePort["index"].(float64)),
-					MultipleConnections: inputValuePort["multipleConnections"].(bool),
-					Socket: Socket{
-						Name: inputValuePort["socket"].(map[string]any)["name"].(string),
-					},
-				},
-				Control: &Control{
-					Id:    inputValueControl["id"].(string),
-					Index: int(inputValueControl["index"].(float64)),
-				},
-				ShowControl: inputValueCpy["showControl"].(bool),
-				Label:       inputValueCpy["label"].(string),
-			})
-		}
-
-		for outputId, outputValue := range node.Outputs {
-			outputValueCpy := outputValue.(map[string]any)
-			if outputValue == nil {
-				continue
-			}
-
-			outputValuePort := outputValueCpy["port"].(map[string]any)
-			outputValueControl := outputValueCpy["control"].(map[string]any)
-
-			inlineNode.Outputs.Add(outputId, &Output[Socket]{
-				Port: &Port[Socket]{
-					Id:                  PortId(outputValuePort["id"].(string)),
-					Label:               outputValuePort["label"].(string),
-					Index:               int(outputValuePort["index"].(float64)),
-					MultipleConnections: outputValuePort["multipleConnections"].(bool),
-					Socket: Socket{
-						Name: outputValuePort["socket"].(map[string]any)["name"].(string),
-					},
-				},
-				Control: &Control{
-					Id:    outputValueControl["id"].(string),
-					Index: int(outputValueControl["index"].(float64)),
-				},
-				ShowControl: outputValueCpy["showControl"].(bool),
-				Label:       outputValueCpy["label"].(string),
-			})
-		}
-
-		for controlId, controlValue := range node.Controls {
-			controlValueCpy := controlValue.(map[string]any)
-			if controlValueCpy == nil {
-				continue
-			}
-
-			controlValueCtrl := controlValueCpy["control"].(map[string]any)
-			controlValueOpts := controlValueCpy["options"].(map[string]any)
-
-			inlineNode.Controls.Add(controlId, &InputControl{
-				Control: &Control{
-					Id:    controlValueCtrl["id"].(string),
-					Index: int(controlValueCtrl["index"].(float64)),
-				},
-				Type: InputControlType(controlValueCpy["type"].(string)),
-				Options: &InputControlOptions{
-					Readonly: ToPtr(controlValueOpts["readonly"].(bool)),
-					Initial:  controlValueOpts["initial"],
-				},
-				Readonly: ToPtr(controlValueCpy["readonly"].(bool)),
-				Value:    ToPtr(controlValueCpy["value"]),
-			})
-		}
-
-		editor.Nodes[id] = inlineNode
+	for _, id := range sortedKeys(jsonData.Nodes) {
+		d.addNode(editor, registry, jsonData.Nodes[id], id)
 	}
 
-	for id, connection := range jsonData.Connections {
-		var inlineConnection = &Connection[ConnectionBase]{
-			E: ConnectionBase{
-				ID:     id,
-				Source: NodeId(connection.Base.Source),
-				Target: NodeId(connection.Base.Target),
-			},
-			Source:       NodeId(connection.Source),
-			SourceOutput: NodeId(connection.SourceOutput),
-			Target:       NodeId(connection.Target),
-			TargetInput:  NodeId(connection.TargetInput),
-		}
-
-		editor.Connections[id] = inlineConnection
+	for _, id := range sortedKeys(jsonData.Connections) {
+		d.addConnection(editor, jsonData.Connections[id], id)
 	}
 
+	if err := d.err(); err != nil {
+		return nil, err
+	}
 	return editor, nil
 }
 
+// NewJSONEditorData decodes the input, every field whose JSON type doesn't match is reported in a *DecodeErrors.
 func NewJSONEditorData(input []byte) (*JSONEditorData, error) {
+	return NewJSONEditorDataWithMigrations(input, DefaultMigrations)
+}
+
+// NewJSONEditorDataWithMigrations is like NewJSONEditorData but upgrades the document with the given migrations.
+func NewJSONEditorDataWithMigrations(input []byte, migrations *MigrationRegistry) (*JSONEditorData, error) {
 	if len(input) == 0 {
 		return nil, errors.New("empty input given")
 	}
 
-	var editorData JSONEditorData
+	var value any
+	if err := json.Unmarshal(input, &value); err != nil {
+		return nil, err
+	}
+
+	var d decoder
+	document, ok := d.object(value, "")
+	if !ok {
+		return nil, d.err()
+	}
+
+	if err := migrations.Migrate(document); err != nil {
+		return nil, err
+	}
 
-	err := json.Unmarshal(input, &editorData)
-	if err != nil {
+	var editorData = d.document(document)
+	if err := d.err(); err != nil {
 		return nil, err
 	}
 
-	return &editorData, nil
+	editorData.Version = FormatVersion
+	return editorData, nil
 }
@@ -0,0 +1,389 @@
+package src
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+)
+
+// Encode writes the graph to w in the format of Deserialize, one node and one connection at a time,
+// so the whole document is never held in memory. The editor is locked only while its nodes and
+// connections are listed.
+func (e *NodeEditor) Encode(w io.Writer) error {
+	e.lock.RLock()
+	var nodes = maps.Clone(e.nodes)
+	var connections = maps.Clone(e.connections)
+	e.lock.RUnlock()
+
+	var buffer = bufio.NewWriter(w)
+
+	fmt.Fprintf(buffer, `{"version":%d,"connections":{`, FormatVersion)
+	for i, id := range sortedKeys(connections) {
+		if err := writeEntry(buffer, i, string(id), connections[id]); err != nil {
+			return fmt.Errorf("connection %s: %w", id, err)
+		}
+	}
+
+	buffer.WriteString(`},"nodes":{`)
+	for i, id := range sortedKeys(nodes) {
+		// nodes are written as Node, the interfaces embedded in their own types break the JSON
+		if err := writeEntry(buffer, i, string(id), nodes[id].Node()); err != nil {
+			return fmt.Errorf("node %s: %w", id, err)
+		}
+	}
+
+	buffer.WriteString(`}}`)
+	return buffer.Flush()
+}
+
+// writeEntry writes the `"key":value` pair of an object, preceded by a comma unless it's the first one.
+func writeEntry(buffer *bufio.Writer, index int, key string, value any) error {
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if index > 0 {
+		buffer.WriteByte(',')
+	}
+	buffer.Write(encodedKey)
+	buffer.WriteByte(':')
+	_, err = buffer.Write(encodedValue)
+	return err
+}
+
+// DecodeJSONEditor reads a document from r, nodes having a type are created by DefaultNodeRegistry.
+func DecodeJSONEditor(r io.Reader) (*JSONEditor, error) {
+	return DecodeJSONEditorWithRegistry(r, DefaultNodeRegistry)
+}
+
+// DecodeJSONEditorWithRegistry reads a document from r and decodes it one node and one connection at a
+// time, so only the decoded graph is held in memory. It reports problems like NewJSONEditorWithRegistry.
+func DecodeJSONEditorWithRegistry(r io.Reader, registry *NodeRegistry) (*JSONEditor, error) {
+	return DecodeJSONEditorWithMigrations(r, registry, DefaultMigrations)
+}
+
+// DecodeJSONEditorWithMigrations is like DecodeJSONEditorWithRegistry but upgrades older documents with
+// the given migrations, as NewJSONEditorDataWithMigrations does.
+//
+// The memory held while decoding is bounded by the decoded graph when the `version` of the document is
+// known before its nodes and connections: when it's the first member, as Encode writes it, or when r
+// is an io.ReadSeeker, which is read once to find the version and then again from where it was. Otherwise
+// the entries coming before the version are kept undecoded until it's read, and every entry of a
+// document without a version, as written before the format has been versioned, until its end.
+//
+// Older documents are migrated entry by entry when every step has been registered with
+// MigrationRegistry.RegisterEntries, the built-in ones are. A step registered with Register needs the
+// whole document, which is then held and migrated at once before it's decoded.
+func DecodeJSONEditorWithMigrations(r io.Reader, registry *NodeRegistry, migrations *MigrationRegistry) (*JSONEditor, error) {
+	var s = &streamDecoder{
+		editor: &JSONEditor{
+			Nodes:       make(map[NodeId]NodeInterface),
+			Connections: make(map[ConnectionId]*Connection[ConnectionBase]),
+		},
+		registry:   registry,
+		migrations: migrations,
+		version:    -1,
+	}
+
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		version, err := findVersion(seeker)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.setVersion(version); err != nil {
+			return nil, err
+		}
+	}
+
+	var stream = json.NewDecoder(r)
+	if err := expectDelim(stream, '{'); err != nil {
+		return nil, err
+	}
+
+	for stream.More() {
+		key, err := objectKey(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "version":
+			err = s.readVersion(stream)
+		case "nodes", "connections":
+			err = streamObject(stream, &s.decoder, key, func(id string) error {
+				return s.readEntry(stream, key, id)
+			})
+		default:
+			err = skipValue(stream)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := expectDelim(stream, '}'); err != nil {
+		return nil, err
+	}
+
+	if s.version < 0 {
+		if err := s.setVersion(nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.finish(); err != nil {
+		return nil, err
+	}
+	if err := s.err(); err != nil {
+		return nil, err
+	}
+	return s.editor, nil
+}
+
+// findVersion reads the `version` of the document from the seeker, nil when there's none, and seeks back
+// to where the document starts. Nodes and connections are skipped token by token.
+func findVersion(seeker io.ReadSeeker) (any, error) {
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	var version any
+	var stream = json.NewDecoder(seeker)
+	if err := expectDelim(stream, '{'); err != nil {
+		return nil, err
+	}
+	for stream.More() {
+		key, err := objectKey(stream)
+		if err != nil {
+			return nil, err
+		}
+		if key == "version" {
+			if err := stream.Decode(&version); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err := skipValue(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = seeker.Seek(start, io.SeekStart)
+	return version, err
+}
+
+// streamDecoder decodes the entries of a document as they're read, once its version is known.
+type streamDecoder struct {
+	decoder
+	editor     *JSONEditor
+	registry   *NodeRegistry
+	migrations *MigrationRegistry
+
+	// version of the document, -1 until it's known
+	version int
+	// read is set once the `version` member has been read
+	read bool
+	// steps migrate the entries to FormatVersion
+	steps []EntryMigration
+	// document holds the entries when the document has to be migrated at once
+	document map[string]any
+	// pending entries read before the version
+	pending []streamEntry
+}
+
+type streamEntry struct {
+	section, id string
+	value       json.RawMessage
+}
+
+func (s *streamDecoder) readVersion(stream *json.Decoder) error {
+	var value any
+	if err := stream.Decode(&value); err != nil {
+		return err
+	}
+	if s.read {
+		return errors.New("document has more than one version")
+	}
+	s.read = true
+
+	// it's been found before the document has been read
+	if s.version >= 0 {
+		return nil
+	}
+	return s.setVersion(value)
+}
+
+// setVersion sets the version of the document, nil when it has none, chooses how its entries are
+// migrated and decodes the entries read before it.
+func (s *streamDecoder) setVersion(value any) error {
+	version, err := documentVersion(map[string]any{"version": value})
+	if err != nil {
+		return err
+	}
+	if version > FormatVersion {
+		return fmt.Errorf("document version %d is newer than the supported version %d", version, FormatVersion)
+	}
+	s.version = version
+
+	if steps, ok := s.migrations.entrySteps(version); ok {
+		s.steps = steps
+	} else {
+		s.document = map[string]any{"version": value, "nodes": map[string]any{}, "connections": map[string]any{}}
+	}
+
+	var pending = s.pending
+	s.pending = nil
+	for _, entry := range pending {
+		var value any
+		if err := json.Unmarshal(entry.value, &value); err != nil {
+			return err
+		}
+		if err := s.decodeEntry(entry.section, entry.id, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readEntry decodes the next node or connection of the stream, it's kept until the version is known.
+func (s *streamDecoder) readEntry(stream *json.Decoder, section, id string) error {
+	if s.version < 0 {
+		var value json.RawMessage
+		if err := stream.Decode(&value); err != nil {
+			return err
+		}
+		s.pending = append(s.pending, streamEntry{section: section, id: id, value: value})
+		return nil
+	}
+
+	var value any
+	if err := stream.Decode(&value); err != nil {
+		return err
+	}
+	return s.decodeEntry(section, id, value)
+}
+
+// decodeEntry migrates the entry to FormatVersion and adds it to the editor, or keeps it when the
+// document is migrated at once.
+func (s *streamDecoder) decodeEntry(section, id string, value any) error {
+	if s.document != nil {
+		s.document[section].(map[string]any)[id] = value
+		return nil
+	}
+
+	if err := migrateEntry(s.steps, section, id, value); err != nil {
+		return err
+	}
+	s.add(section, id, value)
+	return nil
+}
+
+// finish migrates the document held at once and adds its entries.
+func (s *streamDecoder) finish() error {
+	if s.document == nil {
+		return nil
+	}
+	if err := s.migrations.Migrate(s.document); err != nil {
+		return err
+	}
+
+	for _, section := range []string{"nodes", "connections"} {
+		if entries, ok := s.optionalObject(s.document[section], jsonPointer("", section)); ok {
+			for _, id := range sortedKeys(entries) {
+				s.add(section, id, entries[id])
+			}
+		}
+	}
+	return nil
+}
+
+func (s *streamDecoder) add(section, id string, value any) {
+	var pointer = jsonPointer("", section, id)
+
+	if section == "nodes" {
+		if data, ok := s.nodeData(value, pointer); ok {
+			s.addNode(s.editor, s.registry, data, NodeId(id))
+		}
+		return
+	}
+	if data, ok := s.connectionData(value, pointer); ok {
+		s.addConnection(s.editor, data, ConnectionId(id))
+	}
+}
+
+// streamObject calls entry for every key of the object the stream is at. A null object is empty.
+func streamObject(stream *json.Decoder, d *decoder, name string, entry func(key string) error) error {
+	token, err := stream.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('{') {
+		d.fail(jsonPointer("", name), "expected an object, got %v", token)
+		return d.err()
+	}
+
+	for stream.More() {
+		key, err := objectKey(stream)
+		if err != nil {
+			return err
+		}
+		if err := entry(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(stream, '}')
+}
+
+// skipValue reads the next value of the stream token by token, without holding it.
+func skipValue(stream *json.Decoder) error {
+	var depth int
+	for {
+		token, err := stream.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func objectKey(stream *json.Decoder) (string, error) {
+	token, err := stream.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("expected an object key, got %v", token)
+	}
+	return key, nil
+}
+
+func expectDelim(stream *json.Decoder, delim json.Delim) error {
+	token, err := stream.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package src
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// TopologicalOrder returns the node ids ordered so that every node comes after the sources
+// of its incoming connections. Independent nodes are ordered by the editor Ordering, by id when
+// there's none. A *CycleError is returned when the graph isn't acyclic.
+func (e *NodeEditor) TopologicalOrder() ([]NodeId, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	ids, edges := e.adjacency()
+	var degree = make(map[NodeId]int, len(ids))
+	for _, targets := range edges {
+		for _, target := range targets {
+			degree[target]++
+		}
+	}
+
+	var queue, order []NodeId
+	for _, id := range ids {
+		if degree[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+
+		for _, target := range edges[id] {
+			if degree[target]--; degree[target] == 0 {
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	if len(order) != len(ids) {
+		return nil, &CycleError{Cycles: findCycles(ids, edges)}
+	}
+
+	return order, nil
+}
+
+// FindCycles returns the node ids of every loop in the graph. Each entry holds the nodes of
+// a strongly connected component, starting from its smallest id and following the connections.
+func (e *NodeEditor) FindCycles() [][]NodeId {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return findCycles(e.adjacency())
+}
+
+// SetDAGMode enables or disables the DAG mode. In DAG mode AddConnection rejects every
+// connection which would close a cycle. Enabling it fails when the graph already has cycles.
+func (e *NodeEditor) SetDAGMode(enabled bool) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if enabled {
+		if cycles := findCycles(e.adjacency()); len(cycles) > 0 {
+			return &CycleError{Cycles: cycles}
+		}
+	}
+
+	e.dag = enabled
+	return nil
+}
+
+// adjacency returns the node ids and the targets of every node, sorted according to the ordering. Connections pointing
+// to unknown nodes are ignored. The caller must hold the lock.
+func (e *NodeEditor) adjacency() ([]NodeId, map[NodeId][]NodeId) {
+	var ids = make([]NodeId, 0, len(e.nodes))
+	for id := range e.nodes {
+		ids = append(ids, id)
+	}
+	e.sortNodeIds(ids)
+
+	var edges = make(map[NodeId][]NodeId)
+	for _, conn := range e.connections {
+		_, sourceOk := e.nodes[conn.Source]
+		_, targetOk := e.nodes[conn.Target]
+		if sourceOk && targetOk {
+			edges[conn.Source] = append(edges[conn.Source], conn.Target)
+		}
+	}
+	for id := range edges {
+		e.sortNodeIds(edges[id])
+	}
+
+	return ids, edges
+}
+
+// pathBetween returns the nodes on a path going from `from` to `to`, nil if there's none. It walks
+// the links instead of the connections, so it only visits the nodes reachable from `from`.
+// The caller must hold the lock.
+func (e *NodeEditor) pathBetween(from, to NodeId) []NodeId {
+	var visited = make(map[NodeId]bool)
+	var path []NodeId
+
+	var visit func(id NodeId) bool
+	visit = func(id NodeId) bool {
+		if visited[id] {
+			return false
+		}
+		visited[id] = true
+		path = append(path, id)
+
+		if id == to {
+			return true
+		}
+
+		var targets = make([]NodeId, 0, len(e.links[id]))
+		for target := range e.links[id] {
+			if _, exists := e.nodes[target]; exists {
+				targets = append(targets, target)
+			}
+		}
+		e.sortNodeIds(targets)
+
+		for _, target := range targets {
+			if visit(target) {
+				return true
+			}
+		}
+
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(from) {
+		return path
+	}
+	return nil
+}
+
+// link counts the connection in the links of the editor and indexes it by its source and target.
+// The caller must hold the lock.
+func (e *NodeEditor) link(conn *Connection[ConnectionBase]) {
+	if e.links[conn.Source] == nil {
+		e.links[conn.Source] = make(map[NodeId]int)
+	}
+	e.links[conn.Source][conn.Target]++
+
+	e.outgoing.add(conn.Source, conn)
+	e.incoming.add(conn.Target, conn)
+}
+
+// unlink removes the connection from the links and the indexes of the editor. The caller must hold the lock.
+func (e *NodeEditor) unlink(conn *Connection[ConnectionBase]) {
+	var targets = e.links[conn.Source]
+	if targets[conn.Target]--; targets[conn.Target] <= 0 {
+		delete(targets, conn.Target)
+	}
+	if len(targets) == 0 {
+		delete(e.links, conn.Source)
+	}
+
+	e.outgoing.remove(conn.Source, conn)
+	e.incoming.remove(conn.Target, conn)
+}
+
+// nodeConnections returns the connections going from or to the node, sorted. The caller must hold the lock.
+func (e *NodeEditor) nodeConnections(nodeID NodeId) []*Connection[ConnectionBase] {
+	var conns = e.outgoing.connections(nodeID, e, nil)
+	for _, conn := range e.incoming.connections(nodeID, e, nil) {
+		// a connection of the node to itself is in both
+		if conn.Source != nodeID {
+			conns = append(conns, conn)
+		}
+	}
+	e.sortConnections(conns)
+	return conns
+}
+
+// connectionIndex holds the connections of every node on one side of them.
+type connectionIndex map[NodeId]map[ConnectionId]*Connection[ConnectionBase]
+
+func (i connectionIndex) add(nodeID NodeId, conn *Connection[ConnectionBase]) {
+	if i[nodeID] == nil {
+		i[nodeID] = make(map[ConnectionId]*Connection[ConnectionBase])
+	}
+	i[nodeID][conn.E.ID] = conn
+}
+
+func (i connectionIndex) remove(nodeID NodeId, conn *Connection[ConnectionBase]) {
+	delete(i[nodeID], conn.E.ID)
+	if len(i[nodeID]) == 0 {
+		delete(i, nodeID)
+	}
+}
+
+// connections returns the connections of the node accepted by the filter, every one when it's nil,
+// sorted as the editor sorts them.
+func (i connectionIndex) connections(nodeID NodeId, e *NodeEditor, filter func(conn *Connection[ConnectionBase]) bool) []*Connection[ConnectionBase] {
+	var conns = make([]*Connection[ConnectionBase], 0, len(i[nodeID]))
+	for _, conn := range i[nodeID] {
+		if filter == nil || filter(conn) {
+			conns = append(conns, conn)
+		}
+	}
+	e.sortConnections(conns)
+	return conns
+}
+
+func (i connectionIndex) clone() connectionIndex {
+	var cloned = make(connectionIndex, len(i))
+	for nodeID, conns := range i {
+		cloned[nodeID] = maps.Clone(conns)
+	}
+	return cloned
+}
+
+func cloneLinks(links map[NodeId]map[NodeId]int) map[NodeId]map[NodeId]int {
+	var cloned = make(map[NodeId]map[NodeId]int, len(links))
+	for source, targets := range links {
+		cloned[source] = maps.Clone(targets)
+	}
+	return cloned
+}
+
+// findCycles runs Tarjan's algorithm over the graph and returns every strongly connected
+// component which forms a loop, that is having more than one node or a self connection.
+func findCycles(ids []NodeId, edges map[NodeId][]NodeId) [][]NodeId {
+	var (
+		index   = make(map[NodeId]int)
+		low     = make(map[NodeId]int)
+		onStack = make(map[NodeId]bool)
+		stack   []NodeId
+		counter int
+		cycles  [][]NodeId
+	)
+
+	var connect func(id NodeId)
+	connect = func(id NodeId) {
+		index[id], low[id] = counter, counter
+		counter++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, target := range edges[id] {
+			if _, seen := index[target]; !seen {
+				connect(target)
+				low[id] = min(low[id], low[target])
+			} else if onStack[target] {
+				low[id] = min(low[id], index[target])
+			}
+		}
+
+		if low[id] != index[id] {
+			return
+		}
+
+		var component = make(map[NodeId]bool)
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component[top] = true
+			if top == id {
+				break
+			}
+		}
+
+		if len(component) > 1 || slices.Contains(edges[id], id) {
+			cycles = append(cycles, orderComponent(component, edges))
+		}
+	}
+
+	for _, id := range ids {
+		if _, seen := index[id]; !seen {
+			connect(id)
+		}
+	}
+
+	slices.SortFunc(cycles, func(a, b []NodeId) int {
+		return cmp.Compare(a[0], b[0])
+	})
+
+	return cycles
+}
+
+// orderComponent lists the nodes of a component in depth-first order starting from its
+// smallest id, which follows the loop for simple cycles.
+func orderComponent(component map[NodeId]bool, edges map[NodeId][]NodeId) []NodeId {
+	var start NodeId
+	for id := range component {
+		if start == "" || id < start {
+			start = id
+		}
+	}
+
+	var ordered []NodeId
+	var visited = make(map[NodeId]bool)
+
+	var visit func(id NodeId)
+	visit = func(id NodeId) {
+		visited[id] = true
+		ordered = append(ordered, id)
+		for _, target := range edges[id] {
+			if component[target] && !visited[target] {
+				visit(target)
+			}
+		}
+	}
+	visit(start)
+
+	return ordered
+}
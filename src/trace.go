@@ -0,0 +1,316 @@
+package src
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// NodeTrace describes a single node evaluation. It's the data of the `nodeEvaluationStarted`,
+// `nodeEvaluationFinished` and `nodeEvaluationFailed` events published by the engines when tracing is enabled.
+type NodeTrace struct {
+	RunId  string
+	NodeId NodeId
+	// Engine `dataflow` or `controlflow`
+	Engine  string
+	Started time.Time
+	// Duration wall time between the start and the end of the evaluation
+	Duration time.Duration
+	// Self Duration minus the time spent waiting on the upstream nodes (dataflow) or running
+	// the forwarded nodes (control flow)
+	Self time.Duration
+	// Inputs snapshot of the resolved inputs (dataflow) or the `input` key the node has been executed with (control flow)
+	Inputs map[string]any
+	// Outputs snapshot of the outputs (dataflow) or the number of forwards of every output key (control flow)
+	Outputs map[string]any
+	// Sources nodes the evaluation depends on, the upstream nodes (dataflow) or the forwarding node (control flow)
+	Sources []NodeId
+	Err     error
+}
+
+type runIdKey struct{}
+
+// WithRunId sets the id the engines use for the traces of a run. A random id is used otherwise.
+func WithRunId(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIdKey{}, runID)
+}
+
+func runIdFrom(ctx context.Context) string {
+	if runID, ok := ctx.Value(runIdKey{}).(string); ok {
+		return runID
+	}
+	return GetUID()
+}
+
+// tracer publishes the trace events of a run, a nil tracer doesn't publish anything.
+type tracer struct {
+	bus    *EventBus
+	runID  string
+	engine string
+}
+
+func newTracer(enabled bool, bus *EventBus, ctx context.Context, engine string) *tracer {
+	if !enabled || bus == nil {
+		return nil
+	}
+	return &tracer{bus: bus, runID: runIdFrom(ctx), engine: engine}
+}
+
+func (t *tracer) start(nodeID NodeId, inputs map[string]any) *NodeTrace {
+	if t == nil {
+		return nil
+	}
+
+	var trace = &NodeTrace{
+		RunId:   t.runID,
+		NodeId:  nodeID,
+		Engine:  t.engine,
+		Started: time.Now(),
+		Inputs:  snapshot(inputs),
+	}
+
+	t.bus.Publish(Event{Type: "nodeEvaluationStarted", Data: *trace})
+	return trace
+}
+
+func (t *tracer) finish(trace *NodeTrace, waited time.Duration, inputs, outputs map[string]any, sources []NodeId, err error) {
+	if t == nil || trace == nil {
+		return
+	}
+
+	trace.Duration = time.Since(trace.Started)
+	trace.Self = max(trace.Duration-waited, 0)
+	trace.Inputs = snapshot(inputs)
+	trace.Outputs = snapshot(outputs)
+	trace.Sources = sources
+	trace.Err = err
+
+	if err != nil {
+		t.bus.Publish(Event{Type: "nodeEvaluationFailed", Data: *trace})
+		return
+	}
+	t.bus.Publish(Event{Type: "nodeEvaluationFinished", Data: *trace})
+}
+
+// snapshot copies the map and the slices in it, so later changes made by the nodes don't leak into traces.
+func snapshot(values map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+
+	var copied = make(map[string]any, len(values))
+	for key, value := range values {
+		if list, ok := value.([]any); ok {
+			value = slices.Clone(list)
+		}
+		copied[key] = value
+	}
+	return copied
+}
+
+// RunReport summarizes the traces of a run.
+type RunReport struct {
+	RunId string
+	// Nodes traces of every evaluation, in the order they have finished
+	Nodes []NodeTrace
+	// Failed traces of the evaluations which have returned an error
+	Failed []NodeTrace
+	// CriticalPath chain of dependent nodes with the highest total self time, from the first to the last
+	CriticalPath []NodeId
+	// CriticalDuration total self time of the critical path
+	CriticalDuration time.Duration
+	// Slowest traces sorted by self time, slowest first
+	Slowest []NodeTrace
+}
+
+// TraceCollectorOptions configures a TraceCollector. Zero fields fall back to their defaults.
+type TraceCollectorOptions struct {
+	// Runs maximum number of runs kept, the traces of the oldest ones are dropped. Default is 100
+	Runs int
+}
+
+// TraceCollector listens the trace events of an editor and builds a RunReport for every run.
+type TraceCollector struct {
+	lock    sync.Mutex
+	limit   int
+	runs    map[string][]NodeTrace
+	order   []string
+	unwatch []func()
+}
+
+// NewTraceCollector creates a TraceCollector subscribed to the given bus until Close is called. opt can be nil.
+func NewTraceCollector(bus *EventBus, opt *TraceCollectorOptions) *TraceCollector {
+	var collector = &TraceCollector{
+		limit: 100,
+		runs:  make(map[string][]NodeTrace),
+	}
+
+	if opt != nil && opt.Runs > 0 {
+		collector.limit = opt.Runs
+	}
+
+	collector.unwatch = []func(){
+		bus.Subscribe("nodeEvaluationFinished", collector.collect),
+		bus.Subscribe("nodeEvaluationFailed", collector.collect),
+	}
+
+	return collector
+}
+
+// Runs returns the ids of the collected runs, in the order they have been seen.
+func (c *TraceCollector) Runs() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return slices.Clone(c.order)
+}
+
+// Report builds the report of the run, nil if the run is unknown.
+func (c *TraceCollector) Report(runID string) *RunReport {
+	c.lock.Lock()
+	traces, ok := c.runs[runID]
+	traces = slices.Clone(traces)
+	c.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	var report = &RunReport{
+		RunId: runID,
+		Nodes: traces,
+	}
+
+	for _, trace := range traces {
+		if trace.Err != nil {
+			report.Failed = append(report.Failed, trace)
+		}
+	}
+
+	report.Slowest = slices.Clone(traces)
+	slices.SortStableFunc(report.Slowest, func(a, b NodeTrace) int {
+		return cmp.Compare(b.Self, a.Self)
+	})
+
+	report.CriticalPath, report.CriticalDuration = criticalPath(traces)
+	return report
+}
+
+// Last returns the report of the latest run, nil if nothing has been collected yet.
+func (c *TraceCollector) Last() *RunReport {
+	c.lock.Lock()
+	if len(c.order) == 0 {
+		c.lock.Unlock()
+		return nil
+	}
+	runID := c.order[len(c.order)-1]
+	c.lock.Unlock()
+
+	return c.Report(runID)
+}
+
+// Close unsubscribes the collector from the bus and drops every collected trace.
+func (c *TraceCollector) Close() {
+	c.lock.Lock()
+	var unwatch = c.unwatch
+	c.unwatch = nil
+	c.lock.Unlock()
+
+	for _, unsubscribe := range unwatch {
+		unsubscribe()
+	}
+	c.Reset()
+}
+
+// Reset drops every collected trace.
+func (c *TraceCollector) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.runs = make(map[string][]NodeTrace)
+	c.order = nil
+}
+
+func (c *TraceCollector) collect(event Event) {
+	trace, ok := event.Data.(NodeTrace)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, seen := c.runs[trace.RunId]; !seen {
+		c.order = append(c.order, trace.RunId)
+		for len(c.order) > c.limit {
+			delete(c.runs, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.runs[trace.RunId] = append(c.runs[trace.RunId], trace)
+}
+
+// criticalPath finds the chain of nodes, linked through their sources, with the highest total self time.
+// A node evaluated several times in a run counts with the sum of its self times.
+func criticalPath(traces []NodeTrace) ([]NodeId, time.Duration) {
+	var self = make(map[NodeId]time.Duration)
+	var sources = make(map[NodeId][]NodeId)
+	var ids []NodeId
+
+	for _, trace := range traces {
+		if _, seen := self[trace.NodeId]; !seen {
+			ids = append(ids, trace.NodeId)
+		}
+		self[trace.NodeId] += trace.Self
+		for _, source := range trace.Sources {
+			if !slices.Contains(sources[trace.NodeId], source) {
+				sources[trace.NodeId] = append(sources[trace.NodeId], source)
+			}
+		}
+	}
+
+	var best = make(map[NodeId]time.Duration)
+	var previous = make(map[NodeId]NodeId)
+	var visiting = make(map[NodeId]bool)
+
+	var longest func(id NodeId) time.Duration
+	longest = func(id NodeId) time.Duration {
+		if total, ok := best[id]; ok {
+			return total
+		}
+		visiting[id] = true
+
+		var upstream time.Duration
+		for _, source := range sources[id] {
+			if _, traced := self[source]; !traced || visiting[source] {
+				continue
+			}
+			if total := longest(source); total > upstream || previous[id] == "" {
+				upstream = total
+				previous[id] = source
+			}
+		}
+
+		visiting[id] = false
+		best[id] = upstream + self[id]
+		return best[id]
+	}
+
+	var last NodeId
+	var total time.Duration
+	for _, id := range ids {
+		if length := longest(id); last == "" || length > total {
+			last, total = id, length
+		}
+	}
+
+	var path []NodeId
+	for id := last; id != ""; id = previous[id] {
+		path = append(path, id)
+	}
+	slices.Reverse(path)
+
+	return path, total
+}
@@ -0,0 +1,126 @@
+package src
+
+import (
+	"maps"
+)
+
+// Transaction runs fn against a transactional view of the editor. The view supports every editor
+// method and validates the changes as the editor does, but nothing is visible outside of it until fn
+// returns. When fn returns nil, the changes are applied at once and subscribers receive a single
+// `transactionCommitted` event whose data is the list of the events the changes would have published.
+// When fn returns an error, every change is discarded and the error is returned.
+//
+// Pre-mutation signals are published on the editor's bus while fn runs, so subscribers can still
+// cancel the changes. The transaction fails with ErrTransactionConflict if the editor has been changed
+// by someone else in the meantime. Changes to the ports and controls of the nodes aren't transactional,
+// and the nodes added by the transaction belong to the editor only once it's committed.
+func (e *NodeEditor) Transaction(fn func(tx *NodeEditor) error) error {
+	return e.transaction(fn, true)
+}
+
+// transaction runs fn as Transaction does. Unless batched, the events of the changes are published one
+// by one once they're applied.
+func (e *NodeEditor) transaction(fn func(tx *NodeEditor) error, batched bool) error {
+	e.lock.RLock()
+	tx, base := e.stage()
+	e.lock.RUnlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.version != base {
+		return ErrTransactionConflict
+	}
+
+	for id, node := range e.nodes {
+		if _, kept := tx.nodes[id]; !kept {
+			e.detach(node.Node())
+		}
+	}
+	for _, node := range tx.nodes {
+		e.attach(node.Node())
+	}
+
+	e.nodes = tx.nodes
+	e.connections = tx.connections
+	e.links = tx.links
+	e.incoming = tx.incoming
+	e.outgoing = tx.outgoing
+	e.sequence = tx.sequence
+	e.nodeSequences = tx.nodeSequences
+	e.connectionSequences = tx.connectionSequences
+
+	switch {
+	case e.staged:
+		// a nested transaction is a part of the outer one
+		for _, event := range tx.pending {
+			e.emit(event)
+		}
+	case batched && len(tx.pending) > 0:
+		e.emit(Event{Type: "transactionCommitted", Data: tx.pending})
+	default:
+		for _, event := range tx.pending {
+			e.emit(event)
+		}
+	}
+	return nil
+}
+
+// stage copies the editor into a transactional view and returns it with the version it's based on.
+// The caller must hold the lock.
+func (e *NodeEditor) stage() (*NodeEditor, uint64) {
+	return &NodeEditor{
+		nodes:               maps.Clone(e.nodes),
+		connections:         maps.Clone(e.connections),
+		eventBus:            e.eventBus,
+		dag:                 e.dag,
+		ordering:            e.ordering,
+		sockets:             e.sockets,
+		types:               e.types,
+		policy:              e.policy,
+		links:               cloneLinks(e.links),
+		incoming:            e.incoming.clone(),
+		outgoing:            e.outgoing.clone(),
+		staged:              true,
+		sequence:            e.sequence,
+		nodeSequences:       maps.Clone(e.nodeSequences),
+		connectionSequences: maps.Clone(e.connectionSequences),
+	}, e.version
+}
+
+// currentVersion returns the version of the editor, it's increased by every change.
+func (e *NodeEditor) currentVersion() uint64 {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.version
+}
+
+// emit publishes the event of a change, or collects it when the editor is a transaction's view.
+// The caller must hold the lock.
+func (e *NodeEditor) emit(event Event) {
+	e.version++
+	if e.staged {
+		e.pending = append(e.pending, event)
+		return
+	}
+	e.eventBus.Publish(event)
+}
+
+// attach binds the node to the editor. The nodes of a transaction are bound once it's committed.
+func (e *NodeEditor) attach(node *Node[NodeBase]) {
+	if !e.staged {
+		node.editor.Store(e)
+	}
+}
+
+// detach unbinds the node from the editor. The nodes of a transaction are unbound once it's committed.
+func (e *NodeEditor) detach(node *Node[NodeBase]) {
+	if !e.staged {
+		node.editor.Store(nil)
+	}
+}
@@ -6,6 +6,8 @@ type ConnectionId string
 
 type NodeBase struct {
 	ID NodeId `json:"id"`
+	// Type name the node type has been registered with in a NodeRegistry, empty for plain nodes
+	Type string `json:"type,omitempty"`
 }
 
 type ConnectionBase struct {
@@ -26,3 +28,16 @@ type NodeExecutor interface {
 type NodeData interface {
 	Data(inputs func() map[string]any) map[string]any
 }
+
+// NodeExecutorE is the error returning variant of NodeExecutor. The control flow engine prefers it
+// over NodeExecutor when a node implements both.
+type NodeExecutorE interface {
+	ExecuteE(input string, forward func(output string)) error
+}
+
+// NodeDataE is the error returning variant of NodeData. The dataflow engine prefers it over NodeData
+// when a node implements both. inputs returns the error of the upstream nodes which have failed,
+// along with the inputs which could be resolved.
+type NodeDataE interface {
+	DataE(inputs func() (map[string]any, error)) (map[string]any, error)
+}
@@ -1,6 +1,7 @@
 package src
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 )
@@ -18,8 +19,30 @@ func ToPtr[T any](v T) *T {
 	return &v
 }
 
-func NewCancellable[T map[string]any](inputs T, fetcher func(f func() T) T) T {
-	return fetcher(func() T {
-		return inputs
-	})
+// NewCancellable runs fetcher and waits for its result unless ctx is done first. In that case
+// the zero value and ctx.Err() are returned while fetcher finishes in the background, so fetcher
+// should watch ctx itself and stop without side effects once it's done.
+func NewCancellable[T any](ctx context.Context, fetcher func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	var done = make(chan result, 1)
+	go func() {
+		value, err := fetcher()
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-done:
+		return res.value, res.err
+	}
 }
@@ -1,12 +1,21 @@
 package test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/ashkan90/auto-core/src"
 	"github.com/wI2L/jsondiff"
+	"io"
 	"log"
 	"os"
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEditorDeserialize(t *testing.T) {
@@ -65,11 +74,685 @@ func TestEditorSerialize(t *testing.T) {
 
 	var editor = src.NewNodeEditorFromJSON(bus, jsonEditor)
 
-	if deserialize := editor.Deserialize(); deserialize != string(input) {
-		patch, _ := jsondiff.CompareJSON([]byte(deserialize), input)
+	// the input predates the versioned format, it's written back with the current version
+	var expected = append([]byte(`{"version":1,`), input[1:]...)
+
+	if deserialize := editor.Deserialize(); deserialize != string(expected) {
+		patch, _ := jsondiff.CompareJSON([]byte(deserialize), expected)
 		b, _ := json.MarshalIndent(patch, "", "    ")
 
 		os.Stdout.Write(b)
 		t.Fail()
 	}
 }
+
+func TestEditorNodeTypes(t *testing.T) {
+	var registry = src.NewNodeRegistry()
+	registry.Register("text", NewTextNode)
+
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	text, err := registry.Create("text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	control, _ := text.Node().Controls.Get("text")
+	control.(*src.InputControl).SetValue("hello")
+	editor.AddNode(text)
+	editor.AddNode(src.NewNode())
+
+	data, err := src.NewJSONEditorData([]byte(editor.Deserialize()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := src.NewJSONEditorWithRegistry(data, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, ok := decoded.Nodes[text.Node().E.ID].(*TextNode)
+	if !ok {
+		t.Fatalf("expected a TextNode, got %T", decoded.Nodes[text.Node().E.ID])
+	}
+
+	if value := restored.Data(nil)["text"]; *value.(*any) != "hello" {
+		t.Errorf("expected the control value to be restored, got %v", value)
+	}
+
+	input, _ := text.Node().Inputs.Get("exec")
+	restoredInput, _ := restored.Node().Inputs.Get("exec")
+	if restoredInput.(*src.Input[src.Socket]).Port.GetId() != input.(*src.Input[src.Socket]).Port.GetId() {
+		t.Error("expected the port ids to be restored")
+	}
+
+	if _, err := src.NewJSONEditorWithRegistry(data, src.NewNodeRegistry()); err == nil {
+		t.Error("expected an error for an unregistered type")
+	}
+
+	// a node built with its constructor is restored as the same type
+	editor.SetNodeRegistry(registry)
+	constructed, _ := editor.AddNode(NewTextNode())
+
+	data, err = src.NewJSONEditorData([]byte(editor.Deserialize()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = src.NewJSONEditorWithRegistry(data, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded.Nodes[constructed.Node().E.ID].(*TextNode); !ok {
+		t.Errorf("expected a TextNode, got %T", decoded.Nodes[constructed.Node().E.ID])
+	}
+}
+
+func TestEditorDecodeErrors(t *testing.T) {
+	var input = []byte(`{"nodes":{"a":{"base":{"id":"a"},"inputs":{"in/1":{"port":{"id":"p","label":3,"index":0.5,"multipleConnections":true,"socket":{"name":"s"}},"control":null,"showControl":true,"label":"In"},"skipped":null},"outputs":{},"controls":{"ctrl":{"control":{"id":"c","index":0},"type":"text","options":null,"value":"v"},"broken":"text"},"selected":null},"b":null},"connections":{"c":{"base":{"id":"c"},"source":"a","sourceOutput":"","target":"a","targetInput":"in/1"}}}`)
+
+	data, err := src.NewJSONEditorData(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = src.NewJSONEditor(data)
+
+	var decodeErrs *src.DecodeErrors
+	if !errors.As(err, &decodeErrs) {
+		t.Fatalf("expected DecodeErrors, got %v", err)
+	}
+
+	var pointers []string
+	for _, decodeErr := range decodeErrs.Errors {
+		pointers = append(pointers, decodeErr.Pointer)
+	}
+	var expected = []string{
+		"/nodes/a/inputs/in~11/port/label",
+		"/nodes/a/inputs/in~11/port/index",
+		"/nodes/a/controls/broken",
+		"/nodes/b",
+		"/connections/c/sourceOutput",
+	}
+	if !slices.Equal(pointers, expected) {
+		t.Errorf("expected problems at %v, got %v", expected, pointers)
+	}
+
+	_, err = src.NewJSONEditorData([]byte(`{"nodes":{"a.b/c~":{"base":{"id":1},"inputs":[],"selected":"yes"},"d":7},"connections":{"c":{"source":1,"target":"a"}}}`))
+	if !errors.As(err, &decodeErrs) {
+		t.Fatalf("expected DecodeErrors for mistyped fields, got %v", err)
+	}
+
+	pointers = nil
+	for _, decodeErr := range decodeErrs.Errors {
+		pointers = append(pointers, decodeErr.Pointer)
+	}
+	expected = []string{
+		"/nodes/a.b~1c~0/base/id",
+		"/nodes/a.b~1c~0/inputs",
+		"/nodes/a.b~1c~0/selected",
+		"/nodes/d",
+		"/connections/c/source",
+	}
+	if !slices.Equal(pointers, expected) {
+		t.Errorf("expected every mistyped field at %v, got %v", expected, pointers)
+	}
+
+	if _, err := src.NewJSONEditorData([]byte(`[]`)); !errors.As(err, &decodeErrs) || decodeErrs.Errors[0].Pointer != "" {
+		t.Errorf("expected a DecodeErrors for a document which isn't an object, got %v", err)
+	}
+}
+
+func TestEditorDocumentVersions(t *testing.T) {
+	current, err := os.ReadFile("testdata/document_v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current = bytes.TrimSpace(current)
+
+	for _, fixture := range []string{"testdata/document_v0.json", "testdata/document_v1.json"} {
+		input, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := src.NewJSONEditorData(input)
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		if data.Version != src.FormatVersion {
+			t.Errorf("%s: expected version %d, got %d", fixture, src.FormatVersion, data.Version)
+		}
+
+		jsonEditor, err := src.NewJSONEditor(data)
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+
+		if deserialize := src.Serialize(src.NewEventBus(), jsonEditor).Deserialize(); deserialize != string(current) {
+			t.Errorf("%s: expected the document to be upgraded to the current format, got %s", fixture, deserialize)
+		}
+	}
+
+	var migrations = src.NewMigrationRegistry()
+	if _, err := src.NewJSONEditorDataWithMigrations([]byte(`{"nodes":{},"connections":{}}`), migrations); err == nil {
+		t.Error("expected an error for a missing migration")
+	}
+
+	var migrated bool
+	migrations.Register(0, func(document map[string]any) error {
+		migrated = true
+		return nil
+	})
+	if _, err := src.NewJSONEditorDataWithMigrations([]byte(`{"nodes":{},"connections":{}}`), migrations); err != nil || !migrated {
+		t.Errorf("expected the document to be migrated, got %v", err)
+	}
+
+	var renames = src.NewMigrationRegistry()
+	renames.Register(0, func(document map[string]any) error {
+		var connections, _ = document["connections"].(map[string]any)
+		for _, value := range connections {
+			var connection, _ = value.(map[string]any)
+			if input, ok := connection["input"]; ok {
+				connection["targetInput"] = input
+				delete(connection, "input")
+			}
+		}
+		return nil
+	})
+	var renamed, renameErr = src.NewJSONEditorDataWithMigrations([]byte(`{"nodes":{},"connections":{"c":{"base":{"id":"c"},"source":"a","sourceOutput":"out","target":"b","input":"in"}}}`), renames)
+	if renameErr != nil {
+		t.Fatalf("expected the renamed field to decode, got %v", renameErr)
+	}
+	if connection := renamed.Connections["c"]; connection.TargetInput != "in" || connection.SourceOutput != "out" {
+		t.Errorf("expected the migration to move input to targetInput, got %+v", connection)
+	}
+	if renamed.Version != src.FormatVersion {
+		t.Errorf("expected the migrated document to be at version %d, got %d", src.FormatVersion, renamed.Version)
+	}
+
+	if _, err := src.NewJSONEditorData([]byte(`{"version":99,"nodes":{},"connections":{}}`)); err == nil {
+		t.Error("expected an error for a newer document")
+	}
+}
+
+func TestEditorDocumentSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(src.DocumentSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range []string{"testdata/document_v0.json", "testdata/document_v1.json"} {
+		input, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := src.ValidateDocument(input); err != nil {
+			t.Errorf("%s: %v", fixture, err)
+		}
+	}
+
+	var input = []byte(`{"version":-1,"nodes":{"a":{"inputs":{"x":{"port":{"id":"p","label":"X","index":1.5,"multipleConnections":true,"socket":{}},"showControl":true,"label":"X"}},"controls":{"c":{"type":"text"}}}},"connections":{"c":{"source":"a","sourceOutput":"","target":"a"}}}`)
+
+	var decodeErrs *src.DecodeErrors
+	if err := src.ValidateDocument(input); !errors.As(err, &decodeErrs) {
+		t.Fatalf("expected DecodeErrors, got %v", err)
+	}
+
+	var pointers []string
+	for _, decodeErr := range decodeErrs.Errors {
+		pointers = append(pointers, decodeErr.Pointer)
+	}
+	var expected = []string{
+		"/connections/c/targetInput",
+		"/connections/c/sourceOutput",
+		"/nodes/a/controls/c/control",
+		"/nodes/a/inputs/x/port/index",
+		"/nodes/a/inputs/x/port/socket/name",
+		"/version",
+	}
+	if !slices.Equal(pointers, expected) {
+		t.Errorf("expected problems at %v, got %v", expected, pointers)
+	}
+
+	// the schema and the decoder agree on the range of integers
+	var outOfRange = []byte(`{"version":1,"nodes":{"a":{"base":{"id":"a"},"inputs":{"x":{"port":{"id":"p","label":"X","index":2147483648,"multipleConnections":true,"socket":{"name":"s"}},"control":null,"showControl":true,"label":"X"}},"outputs":{},"controls":{},"selected":null}},"connections":{}}`)
+	if err := src.ValidateDocument(outOfRange); !errors.As(err, &decodeErrs) || decodeErrs.Errors[0].Pointer != "/nodes/a/inputs/x/port/index" {
+		t.Errorf("expected the schema to reject the index, got %v", err)
+	}
+	data, err := src.NewJSONEditorData(outOfRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.NewJSONEditor(data); !errors.As(err, &decodeErrs) || decodeErrs.Errors[0].Pointer != "/nodes/a/inputs/x/port/index" {
+		t.Errorf("expected the decoder to reject the index, got %v", err)
+	}
+}
+
+func TestEditorDocumentSchemaAcceptsEncoded(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	a, _ := editor.AddNode(NewNumberNode(1))
+	b, _ := editor.AddNode(NewNumberNode(2))
+	sum, _ := editor.AddNode(NewSumNode())
+	text, _ := editor.AddNode(NewTextNode())
+	control, _ := text.Node().Controls.Get("text")
+	control.(*src.InputControl).SetValue("hello")
+	editor.AddNode(src.NewNode())
+
+	for _, connection := range []*src.Connection[src.ConnectionBase]{
+		src.NewConnection(a, "value", sum, "a"),
+		src.NewConnection(b, "value", sum, "b"),
+	} {
+		if err := editor.AddConnection(connection); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := editor.Encode(&encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, document := range map[string][]byte{
+		"Encode":      encoded.Bytes(),
+		"Deserialize": []byte(editor.Deserialize()),
+	} {
+		if err := src.ValidateDocument(document); err != nil {
+			t.Errorf("%s: expected the output to match the schema, got %v", name, err)
+		}
+	}
+}
+
+// TestEditorDocumentSchemaAgreesWithDecoder walks the schema over a document using every definition and,
+// for each member it describes, removes it, nulls it and moves integers across their bounds, expecting the
+// schema, the decoder and the stream decoder to accept or reject each document alike.
+func TestEditorDocumentSchemaAgreesWithDecoder(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(src.DocumentSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var port = `{"port":{"id":"p","label":"P","index":0,"multipleConnections":true,"socket":{"name":"s"}},"control":{"id":"c","index":0},"showControl":true,"label":"P"}`
+	var valid = []byte(`{"version":1,"nodes":{"a":{"base":{"id":"a","type":null},"inputs":{"in":` + port + `},"outputs":{"out":` + port + `},` +
+		`"controls":{"text":{"control":{"id":"t","index":0},"type":"text","options":{"readonly":false,"initial":"x"},"readonly":false,"value":"x"}},"selected":false}},` +
+		`"connections":{"c":{"base":{"id":"c","source":"a","target":"a"},"source":"a","sourceOutput":"out","target":"a","targetInput":"in"}}}`)
+
+	var document map[string]any
+	if err := json.Unmarshal(valid, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	var cases int
+	var check = func(name string, path []string, change func(parent map[string]any, key string), accepted bool) {
+		cases++
+
+		var changed map[string]any
+		json.Unmarshal(valid, &changed)
+		var parent = changed
+		for _, key := range path[:len(path)-1] {
+			parent = parent[key].(map[string]any)
+		}
+		change(parent, path[len(path)-1])
+		input, _ := json.Marshal(changed)
+
+		var pointer = "/" + strings.Join(path, "/")
+		if err := src.ValidateDocument(input); (err == nil) != accepted {
+			t.Errorf("%s %s: expected the schema to accept it: %v, got %v", name, pointer, accepted, err)
+		}
+		if _, err := decodeDocument(input); (err == nil) != accepted {
+			t.Errorf("%s %s: expected the decoder to accept it: %v, got %v", name, pointer, accepted, err)
+		}
+		if _, err := src.DecodeJSONEditor(bytes.NewReader(input)); (err == nil) != accepted {
+			t.Errorf("%s %s: expected the stream decoder to accept it: %v, got %v", name, pointer, accepted, err)
+		}
+	}
+
+	var walk func(definition map[string]any, value any, path []string)
+	walk = func(definition map[string]any, value any, path []string) {
+		definition = resolveSchema(schema, definition)
+		if branches, ok := definition["anyOf"].([]any); ok {
+			for _, branch := range branches {
+				walk(branch.(map[string]any), value, path)
+			}
+		}
+
+		object, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+
+		var member = func(key string, definition map[string]any, required bool) {
+			var memberPath = append(slices.Clip(path), key)
+
+			check("removed", memberPath, func(parent map[string]any, key string) { delete(parent, key) }, !required)
+			check("null", memberPath, func(parent map[string]any, key string) { parent[key] = nil }, allowsNull(schema, definition))
+
+			var resolved = resolveSchema(schema, definition)
+			if minimum, ok := resolved["minimum"].(float64); ok {
+				for _, bound := range []struct {
+					value    float64
+					accepted bool
+				}{{minimum, true}, {minimum - 1, false}, {resolved["maximum"].(float64), true}, {resolved["maximum"].(float64) + 1, false}, {0.5, false}} {
+					check(fmt.Sprint(bound.value), memberPath, func(parent map[string]any, key string) { parent[key] = bound.value }, bound.accepted)
+				}
+			}
+
+			if child, ok := object[key]; ok {
+				walk(definition, child, memberPath)
+			}
+		}
+
+		required, _ := definition["required"].([]any)
+		if properties, ok := definition["properties"].(map[string]any); ok {
+			for _, key := range sortedKeys(properties) {
+				member(key, properties[key].(map[string]any), slices.Contains(required, any(key)))
+			}
+		}
+		if additional, ok := definition["additionalProperties"].(map[string]any); ok {
+			for _, key := range sortedKeys(object) {
+				member(key, additional, false)
+			}
+		}
+	}
+
+	walk(schema, document, nil)
+	if cases < 100 {
+		t.Errorf("expected the schema to describe every member of the document, checked %d cases", cases)
+	}
+}
+
+// decodeDocument decodes the document the way NewJSONEditorData and NewJSONEditor do together.
+func decodeDocument(input []byte) (*src.JSONEditor, error) {
+	data, err := src.NewJSONEditorData(input)
+	if err != nil {
+		return nil, err
+	}
+	return src.NewJSONEditor(data)
+}
+
+// resolveSchema follows the `$ref` of the definition to `$defs`.
+func resolveSchema(schema, definition map[string]any) map[string]any {
+	if ref, ok := definition["$ref"].(string); ok {
+		return schema["$defs"].(map[string]any)[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+	}
+	return definition
+}
+
+// allowsNull tells whether the definition accepts null, which a definition without a type does.
+func allowsNull(schema, definition map[string]any) bool {
+	definition = resolveSchema(schema, definition)
+	if branches, ok := definition["anyOf"].([]any); ok {
+		return slices.ContainsFunc(branches, func(branch any) bool {
+			return allowsNull(schema, branch.(map[string]any))
+		})
+	}
+
+	switch types := definition["type"].(type) {
+	case nil:
+		return true
+	case string:
+		return types == "null"
+	case []any:
+		return slices.Contains(types, any("null"))
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestEditorStream(t *testing.T) {
+	current, err := os.ReadFile("testdata/document_v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current = bytes.TrimSpace(current)
+
+	for _, fixture := range []string{"testdata/document_v0.json", "testdata/document_v1.json"} {
+		input, err := os.Open(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jsonEditor, err := src.DecodeJSONEditor(input)
+		input.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+
+		var editor = src.Serialize(src.NewEventBus(), jsonEditor)
+
+		var output bytes.Buffer
+		if err := editor.Encode(&output); err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != string(current) {
+			t.Errorf("%s: expected %s, got %s", fixture, current, output.String())
+		}
+		if deserialize := editor.Deserialize(); deserialize != output.String() {
+			t.Errorf("%s: expected Encode to write %s, got %s", fixture, deserialize, output.String())
+		}
+	}
+
+	// a seeker is read once to find the version, the entries read before the version are held otherwise
+	var readers = map[string]func(input string) io.Reader{
+		"seeker": func(input string) io.Reader { return strings.NewReader(input) },
+		"reader": func(input string) io.Reader { return io.MultiReader(strings.NewReader(input)) },
+	}
+
+	for name, reader := range readers {
+		// the version may come after the graph
+		var versionLast = `{` + strings.TrimSuffix(strings.TrimPrefix(string(current), `{"version":1,`), `}`) + `,"version":1}`
+		if jsonEditor, err := src.DecodeJSONEditor(reader(versionLast)); err != nil {
+			t.Errorf("%s: expected a document with the version last to decode, got %v", name, err)
+		} else {
+			var output bytes.Buffer
+			src.Serialize(src.NewEventBus(), jsonEditor).Encode(&output)
+			if output.String() != string(current) {
+				t.Errorf("%s: expected %s, got %s", name, current, output.String())
+			}
+		}
+
+		if jsonEditor, err := src.DecodeJSONEditor(reader(`{}`)); err != nil || len(jsonEditor.Nodes) != 0 {
+			t.Errorf("%s: expected an empty editor, got %v", name, err)
+		}
+
+		for _, input := range []string{`{"version":99}`, `{"version":-1}`, `{"version":"1"}`, `{"version":1,"version":1}`, `{"nodes":{},"version":1,"version":1}`} {
+			if _, err := src.DecodeJSONEditor(reader(input)); err == nil {
+				t.Errorf("%s: %s: expected an error", name, input)
+			}
+		}
+	}
+
+	// entry migrations are run while the document is streamed, document migrations on the whole document
+	var entries = src.NewMigrationRegistry()
+	entries.RegisterEntries(0, src.EntryMigration{
+		Connection: func(id string, connection map[string]any) error {
+			connection["targetInput"] = connection["input"]
+			delete(connection, "input")
+			return nil
+		},
+	})
+	var documents = src.NewMigrationRegistry()
+	documents.Register(0, func(document map[string]any) error {
+		// the input is found on the target node
+		var nodes, _ = document["nodes"].(map[string]any)
+		var connections, _ = document["connections"].(map[string]any)
+		for _, value := range connections {
+			var connection = value.(map[string]any)
+			var target = nodes[connection["target"].(string)].(map[string]any)
+			for key := range target["inputs"].(map[string]any) {
+				connection["targetInput"] = key
+			}
+			delete(connection, "input")
+		}
+		return nil
+	})
+
+	var node = `{"base":{"id":"%s"},"inputs":{"in":{"port":{"id":"p%[1]s","label":"In","index":0,"multipleConnections":true,"socket":{"name":"s"}},"control":null,"showControl":true,"label":"In"}},"outputs":{"out":{"port":{"id":"q%[1]s","label":"Out","index":0,"multipleConnections":true,"socket":{"name":"s"}},"control":null,"showControl":true,"label":"Out"}},"controls":{},"selected":null}`
+	var graph = fmt.Sprintf(`"connections":{"c":{"base":{"id":"c"},"source":"a","sourceOutput":"out","target":"b","input":"in"}},"nodes":{"a":`+node+`,"b":`+node+`}`, "a", "b")
+	for migrationsName, migrations := range map[string]*src.MigrationRegistry{"entries": entries, "documents": documents} {
+		for name, reader := range readers {
+			for _, input := range []string{`{"version":0,` + graph + `}`, `{` + graph + `}`, `{` + graph + `,"version":0}`} {
+				jsonEditor, err := src.DecodeJSONEditorWithMigrations(reader(input), src.DefaultNodeRegistry, migrations)
+				if err != nil {
+					t.Errorf("%s, %s: %s: %v", migrationsName, name, input, err)
+					continue
+				}
+				if connection := jsonEditor.Connections["c"]; connection == nil || connection.TargetInput != "in" || len(jsonEditor.Nodes) != 2 {
+					t.Errorf("%s, %s: %s: expected the migrated connection, got %+v", migrationsName, name, input, connection)
+				}
+			}
+		}
+
+		data, err := src.NewJSONEditorDataWithMigrations([]byte(`{`+graph+`}`), migrations)
+		if err != nil || data.Connections["c"].TargetInput != "in" {
+			t.Errorf("%s: expected NewJSONEditorDataWithMigrations to migrate the connection, got %v", migrationsName, err)
+		}
+	}
+
+	var input = []byte(`{"version":1,"extra":[1,{"a":2}],"connections":{"c":{"base":{"id":"c"},"source":"a","sourceOutput":1,"target":"a","targetInput":"in"}},"nodes":{"a":{"base":{"id":"a"},"inputs":[]},"b":null}}`)
+
+	var decodeErrs *src.DecodeErrors
+	if _, err := src.DecodeJSONEditor(bytes.NewReader(input)); !errors.As(err, &decodeErrs) {
+		t.Fatalf("expected DecodeErrors, got %v", err)
+	}
+
+	var pointers []string
+	for _, decodeErr := range decodeErrs.Errors {
+		pointers = append(pointers, decodeErr.Pointer)
+	}
+	var expected = []string{
+		"/connections/c/sourceOutput",
+		"/nodes/a/inputs",
+		"/nodes/b",
+	}
+	if !slices.Equal(pointers, expected) {
+		t.Errorf("expected problems at %v, got %v", expected, pointers)
+	}
+}
+
+// graphDocument generates a chain of nodes, each one connected to the next, without holding the document.
+type graphDocument struct {
+	nodes       int
+	versionLast bool
+	next        int
+	buffer      bytes.Buffer
+}
+
+func (g *graphDocument) Read(p []byte) (int, error) {
+	for g.buffer.Len() < len(p) && g.next <= 2*g.nodes+1 {
+		g.write()
+		g.next++
+	}
+	if g.buffer.Len() == 0 {
+		return 0, io.EOF
+	}
+	return g.buffer.Read(p)
+}
+
+func (g *graphDocument) write() {
+	var port = `{"port":{"id":"%s","label":"%s","index":0,"multipleConnections":true,"socket":{"name":"number"}},"control":null,"showControl":true,"label":"%[2]s"}`
+
+	switch i := g.next; {
+	case i == 0 && g.versionLast:
+		g.buffer.WriteString(`{"nodes":{`)
+	case i == 0:
+		g.buffer.WriteString(`{"version":1,"nodes":{`)
+	case i <= g.nodes:
+		if i > 1 {
+			g.buffer.WriteByte(',')
+		}
+		fmt.Fprintf(&g.buffer, `"n%d":{"base":{"id":"n%[1]d"},"inputs":{"in":`+port+`},"outputs":{"out":`+port+`},"controls":{},"selected":null}`,
+			i, fmt.Sprintf("i%d", i), "In", fmt.Sprintf("o%d", i), "Out")
+	case i == g.nodes+1:
+		g.buffer.WriteString(`},"connections":{`)
+	case i < 2*g.nodes+1:
+		var source = i - g.nodes - 1
+		if source > 1 {
+			g.buffer.WriteByte(',')
+		}
+		fmt.Fprintf(&g.buffer, `"c%d":{"base":{"id":"c%[1]d"},"source":"n%[1]d","sourceOutput":"out","target":"n%d","targetInput":"in"}`, source, source+1)
+	case g.versionLast:
+		g.buffer.WriteString(`},"version":1}`)
+	default:
+		g.buffer.WriteString(`}}`)
+	}
+}
+
+// peakHeap samples the heap until it's stopped and returns the highest usage seen.
+func peakHeap() (stop func() uint64) {
+	var done = make(chan struct{})
+	var result = make(chan uint64)
+
+	go func() {
+		var sample = []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+		var peak uint64
+		for {
+			metrics.Read(sample)
+			peak = max(peak, sample[0].Value.Uint64())
+
+			select {
+			case <-done:
+				result <- peak
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		return <-result
+	}
+}
+
+func benchmarkDecode(b *testing.B, decode func(r io.Reader) (*src.JSONEditor, error), versionLast bool) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const nodes = 100_000
+	b.ReportAllocs()
+
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		var stop = peakHeap()
+
+		jsonEditor, err := decode(&graphDocument{nodes: nodes, versionLast: versionLast})
+		peak = max(peak, stop())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(jsonEditor.Nodes) != nodes || len(jsonEditor.Connections) != nodes-1 {
+			b.Fatalf("expected %d nodes, got %d", nodes, len(jsonEditor.Nodes))
+		}
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+}
+
+func BenchmarkDecodeJSONEditor(b *testing.B) {
+	benchmarkDecode(b, src.DecodeJSONEditor, false)
+}
+
+func BenchmarkDecodeJSONEditorVersionLast(b *testing.B) {
+	benchmarkDecode(b, src.DecodeJSONEditor, true)
+}
+
+// BenchmarkNewJSONEditorData reads the whole document before decoding it, to compare with the stream.
+func BenchmarkNewJSONEditorData(b *testing.B) {
+	benchmarkDecode(b, func(r io.Reader) (*src.JSONEditor, error) {
+		input, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		data, err := src.NewJSONEditorData(input)
+		if err != nil {
+			return nil, err
+		}
+		return src.NewJSONEditor(data)
+	}, false)
+}
@@ -0,0 +1,565 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/ashkan90/auto-core/src"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDataflowEngineFetch(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	one, two := NewNumberNode(1), NewNumberNode(2)
+	left, right, total := NewSumNode(), NewSumNode(), NewSumNode()
+	for _, node := range []src.NodeInterface{one, two, left, right, total} {
+		editor.AddNode(node)
+	}
+
+	editor.AddConnection(src.NewConnection(one, "value", left, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", left, "b"))
+	editor.AddConnection(src.NewConnection(one, "value", right, "a"))
+	editor.AddConnection(src.NewConnection(left, "value", total, "a"))
+	editor.AddConnection(src.NewConnection(right, "value", total, "b"))
+
+	var engine = src.NewDataflowEngine(editor, nil)
+
+	outputs, err := engine.Fetch(total.Node().E.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs["value"] != 4.0 {
+		t.Errorf("expected 4, got %v", outputs["value"])
+	}
+	if one.Calls != 1 {
+		t.Errorf("shared upstream node evaluated %d times", one.Calls)
+	}
+
+	if _, err := engine.Fetch("missing"); err == nil {
+		t.Error("expected an error for a missing node")
+	}
+}
+
+func TestControlFlowEngineExecute(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var visited []string
+
+	start, middle, end := NewStepNode("start", &visited), NewStepNode("middle", &visited), NewStepNode("end", &visited)
+	for _, node := range []src.NodeInterface{start, middle, end} {
+		editor.AddNode(node)
+	}
+
+	editor.AddConnection(src.NewConnection(start, "exec", middle, "exec"))
+	editor.AddConnection(src.NewConnection(middle, "exec", end, "exec"))
+
+	var engine = src.NewControlFlowEngine(editor, nil)
+	if err := engine.Execute(start.Node().E.ID, "trigger"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(visited) != 3 || visited[0] != "start:trigger" || visited[2] != "end:exec" {
+		t.Errorf("unexpected execution order %v", visited)
+	}
+
+	var dataOnly = src.NewControlFlowEngine(editor, &src.ControlFlowOptions{
+		Sockets: &src.FlowSockets{Control: []string{"signal"}},
+	})
+	if err := dataOnly.Execute(start.Node().E.ID, "trigger"); err == nil {
+		t.Error("expected forwarding a non control-flow port to fail")
+	}
+}
+
+type blockingNode struct {
+	*NumberNode
+	release chan struct{}
+}
+
+func (n *blockingNode) Data(inputs func() map[string]any) map[string]any {
+	<-n.release
+	return n.NumberNode.Data(inputs)
+}
+
+func TestControlFlowEngineLoop(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var visited []string
+
+	var loop = NewStepNode("loop", &visited)
+	editor.AddNode(loop)
+	editor.AddConnection(src.NewConnection(loop, "exec", loop, "exec"))
+
+	const steps = 100_000
+	var engine = src.NewControlFlowEngine(editor, &src.ControlFlowOptions{MaxSteps: steps})
+
+	var graphErr *src.GraphError
+	if err := engine.Execute(loop.Node().E.ID, "trigger"); !errors.As(err, &graphErr) || !errors.Is(err, src.ErrStepLimit) {
+		t.Fatalf("expected the step limit to stop the loop, got %v", err)
+	}
+	if len(visited) != steps {
+		t.Errorf("expected %d steps, got %d", steps, len(visited))
+	}
+	if path := graphErr.Errors[0].Path; len(path) != steps+1 {
+		t.Errorf("expected the path to hold every step, got %d nodes", len(path))
+	}
+}
+
+func TestDataflowEngineFetchContextDeadline(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	slow := &blockingNode{NumberNode: NewNumberNode(1), release: make(chan struct{})}
+	defer close(slow.release)
+	sum := NewSumNode()
+	editor.AddNode(slow)
+	editor.AddNode(sum)
+	editor.AddConnection(src.NewConnection(slow, "value", sum, "a"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := src.NewDataflowEngine(editor, nil).FetchContext(ctx, sum.Node().E.ID)
+
+	var cancelErr *src.CancelledError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("expected a CancelledError, got %v", err)
+	}
+	if cancelErr.NodeId != sum.Node().E.ID || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected cancellation %v", err)
+	}
+}
+
+func TestDataflowEngineCancelledRunIsNotCached(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+
+	slow := &blockingNode{NumberNode: NewNumberNode(1), release: make(chan struct{})}
+	editor.AddNode(slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var finished = make(chan struct{}, 1)
+	bus.Subscribe("nodeEvaluationStarted", func(src.Event) {
+		cancel()
+	})
+	bus.Subscribe("nodeEvaluationFinished", func(src.Event) {
+		finished <- struct{}{}
+	})
+
+	go func() {
+		// the node is released once the caller has given up on it
+		<-ctx.Done()
+		close(slow.release)
+	}()
+
+	var engine = src.NewDataflowEngine(editor, &src.DataflowOptions{Trace: true})
+	if _, err := engine.FetchContext(ctx, slow.Node().E.ID); !errors.As(err, new(*src.CancelledError)) {
+		t.Fatalf("expected a CancelledError, got %v", err)
+	}
+	<-finished
+
+	outputs, err := engine.Fetch(slow.Node().E.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-finished
+	if outputs["value"] != 1.0 || slow.Calls != 2 {
+		t.Errorf("expected the abandoned evaluation not to be cached, got %v after %d calls", outputs["value"], slow.Calls)
+	}
+}
+
+type slowNode struct {
+	*NumberNode
+	calls *atomic.Int32
+}
+
+func (n *slowNode) Data(inputs func() map[string]any) map[string]any {
+	n.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	ctrl, _ := n.Node().Controls.Get("value")
+	return map[string]any{"value": ctrl.(*src.InputControl).GetValue()}
+}
+
+// barrier is passed once `size` nodes have arrived at it.
+type barrier struct {
+	size    int32
+	arrived atomic.Int32
+	all     chan struct{}
+}
+
+func newBarrier(size int32) *barrier {
+	return &barrier{size: size, all: make(chan struct{})}
+}
+
+// wait reports whether the other nodes have arrived before the timeout.
+func (b *barrier) wait() bool {
+	if b.arrived.Add(1) == b.size {
+		close(b.all)
+	}
+
+	select {
+	case <-b.all:
+		return true
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+// barrierNode waits in its Data method until the other nodes of the barrier have started theirs.
+type barrierNode struct {
+	*NumberNode
+	calls   *atomic.Int32
+	barrier *barrier
+	serial  *atomic.Bool
+}
+
+func (n *barrierNode) Data(inputs func() map[string]any) map[string]any {
+	n.calls.Add(1)
+	if !n.barrier.wait() {
+		n.serial.Store(true)
+	}
+	ctrl, _ := n.Node().Controls.Get("value")
+	return map[string]any{"value": ctrl.(*src.InputControl).GetValue()}
+}
+
+func TestDataflowEngineParallelBranches(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var calls atomic.Int32
+	var serial atomic.Bool
+	var started = newBarrier(2)
+
+	shared := &barrierNode{NumberNode: NewNumberNode(1), calls: &calls, barrier: started, serial: &serial}
+	other := &barrierNode{NumberNode: NewNumberNode(2), calls: &calls, barrier: started, serial: &serial}
+	total := NewTotalNode()
+	editor.AddNode(shared)
+	editor.AddNode(other)
+	editor.AddNode(total)
+
+	var consumers []*SumNode
+	for i := 0; i < 4; i++ {
+		consumer := NewSumNode()
+		editor.AddNode(consumer)
+		editor.AddConnection(src.NewConnection(shared, "value", consumer, "a"))
+		editor.AddConnection(src.NewConnection(other, "value", consumer, "b"))
+		editor.AddConnection(src.NewConnection(consumer, "value", total, "a"))
+		consumers = append(consumers, consumer)
+	}
+
+	var engine = src.NewDataflowEngine(editor, &src.DataflowOptions{Workers: 4})
+
+	outputs, err := engine.Fetch(total.Node().E.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if outputs["value"] != 12.0 {
+		t.Errorf("expected 12, got %v", outputs["value"])
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected every slow node to be evaluated once, got %d calls", calls.Load())
+	}
+	if serial.Load() {
+		t.Error("independent branches weren't evaluated concurrently")
+	}
+}
+
+func TestDataflowEngineIncremental(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	one, two, three := NewNumberNode(1), NewNumberNode(2), NewNumberNode(3)
+	left, right, total := NewSumNode(), NewSumNode(), NewSumNode()
+	for _, node := range []src.NodeInterface{one, two, three, left, right, total} {
+		editor.AddNode(node)
+	}
+
+	editor.AddConnection(src.NewConnection(one, "value", left, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", right, "a"))
+	editor.AddConnection(src.NewConnection(left, "value", total, "a"))
+	editor.AddConnection(src.NewConnection(right, "value", total, "b"))
+
+	var engine = src.NewDataflowEngine(editor, nil)
+	if _, err := engine.Fetch(total.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl, _ := one.Node().Controls.Get("value")
+	ctrl.(*src.InputControl).SetValue(10.0)
+
+	outputs, _ := engine.Fetch(total.Node().E.ID)
+	if outputs["value"] != 12.0 {
+		t.Errorf("expected 12 after the control change, got %v", outputs["value"])
+	}
+	if one.Calls != 2 || left.Calls != 2 || total.Calls != 2 || two.Calls != 1 || right.Calls != 1 {
+		t.Errorf("unexpected recomputations one=%d left=%d total=%d two=%d right=%d",
+			one.Calls, left.Calls, total.Calls, two.Calls, right.Calls)
+	}
+
+	conn := src.NewConnection(three, "value", right, "b")
+	editor.AddConnection(conn)
+
+	outputs, _ = engine.Fetch(total.Node().E.ID)
+	if outputs["value"] != 15.0 || left.Calls != 2 || right.Calls != 2 {
+		t.Errorf("unexpected result %v after adding a connection", outputs["value"])
+	}
+
+	editor.RemoveConnection(conn.E.ID)
+
+	outputs, _ = engine.Fetch(total.Node().E.ID)
+	if outputs["value"] != 12.0 || right.Calls != 3 {
+		t.Errorf("unexpected result %v after removing a connection", outputs["value"])
+	}
+
+	engine.Close()
+	if _, err := engine.Fetch(total.Node().E.ID); err != nil || total.Calls != 5 {
+		t.Errorf("expected a closed engine to drop its cache, got %d calls", total.Calls)
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	var bus = src.NewEventBus()
+	var first, second int
+
+	unsubscribe := bus.Subscribe("changed", func(src.Event) { first++ })
+	bus.Subscribe("changed", func(src.Event) { second++ })
+
+	bus.Publish(src.Event{Type: "changed"})
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(src.Event{Type: "changed"})
+
+	if first != 1 || second != 2 {
+		t.Errorf("expected the removed handler to stop receiving events, got %d and %d calls", first, second)
+	}
+}
+
+func TestDataflowEngineTrace(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+	var collector = src.NewTraceCollector(bus, &src.TraceCollectorOptions{Runs: 1})
+	var calls atomic.Int32
+
+	slow := &slowNode{NumberNode: NewNumberNode(1), calls: &calls}
+	fast := NewNumberNode(2)
+	left, total := NewSumNode(), NewSumNode()
+	for _, node := range []src.NodeInterface{slow, fast, left, total} {
+		editor.AddNode(node)
+	}
+
+	editor.AddConnection(src.NewConnection(slow, "value", left, "a"))
+	editor.AddConnection(src.NewConnection(left, "value", total, "a"))
+	editor.AddConnection(src.NewConnection(fast, "value", total, "b"))
+
+	var engine = src.NewDataflowEngine(editor, &src.DataflowOptions{Trace: true})
+	ctx := src.WithRunId(context.Background(), "run-1")
+	if _, err := engine.FetchContext(ctx, total.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	report := collector.Report("run-1")
+	if report == nil || len(report.Nodes) != 4 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if report.Slowest[0].NodeId != slow.Node().E.ID {
+		t.Errorf("expected the slow node to be the slowest, got %s", report.Slowest[0].NodeId)
+	}
+
+	want := []src.NodeId{slow.Node().E.ID, left.Node().E.ID, total.Node().E.ID}
+	if len(report.CriticalPath) != 3 || report.CriticalPath[0] != want[0] || report.CriticalPath[2] != want[2] {
+		t.Errorf("unexpected critical path %v", report.CriticalPath)
+	}
+
+	last := report.Nodes[len(report.Nodes)-1]
+	if last.NodeId != total.Node().E.ID || last.Outputs["value"] != 3.0 || len(last.Inputs["a"].([]any)) != 1 {
+		t.Errorf("unexpected trace of the fetched node %+v", last)
+	}
+
+	engine.Reset()
+	if _, err := engine.FetchContext(src.WithRunId(context.Background(), "run-2"), total.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+	if runs := collector.Runs(); len(runs) != 1 || runs[0] != "run-2" {
+		t.Errorf("expected only the latest run to be kept, got %v", runs)
+	}
+
+	collector.Close()
+	engine.Reset()
+	if _, err := engine.FetchContext(src.WithRunId(context.Background(), "run-3"), total.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+	if runs := collector.Runs(); len(runs) != 0 {
+		t.Errorf("expected a closed collector not to collect anything, got %v", runs)
+	}
+}
+
+func TestDebugSessionStepping(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var visited []string
+
+	start, middle, end := NewStepNode("start", &visited), NewStepNode("middle", &visited), NewStepNode("end", &visited)
+	for _, node := range []src.NodeInterface{start, middle, end} {
+		editor.AddNode(node)
+	}
+
+	editor.AddConnection(src.NewConnection(start, "exec", middle, "exec"))
+	editor.AddConnection(src.NewConnection(middle, "exec", end, "exec"))
+
+	var session = src.NewDebugSession()
+	session.AddBreakpoint(middle.Node().E.ID)
+
+	var engine = src.NewControlFlowEngine(editor, &src.ControlFlowOptions{Debugger: session})
+	var done = make(chan error, 1)
+	go func() {
+		done <- engine.Execute(start.Node().E.ID, "trigger")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	frame, err := session.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.NodeId != middle.Node().E.ID || frame.Stage != src.FrameEnter || frame.Inputs["input"] != "exec" {
+		t.Fatalf("unexpected frame %+v", frame)
+	}
+	if len(visited) != 1 {
+		t.Errorf("the run didn't pause before the breakpoint node, visited %v", visited)
+	}
+
+	session.StepOver()
+	frame, _ = session.Wait(ctx)
+	if frame.NodeId != middle.Node().E.ID || frame.Stage != src.FrameLeave || frame.Outputs["exec"] != 1 {
+		t.Fatalf("step over should stop once the node is done, got %+v", frame)
+	}
+	if len(visited) != 3 {
+		t.Errorf("step over should run the nested nodes, visited %v", visited)
+	}
+
+	session.Step()
+	frame, _ = session.Wait(ctx)
+	if frame.NodeId != start.Node().E.ID || frame.Stage != src.FrameLeave {
+		t.Fatalf("unexpected frame after step %+v", frame)
+	}
+
+	session.Continue()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+type failingNode struct {
+	*NumberNode
+}
+
+func (n *failingNode) DataE(_ func() (map[string]any, error)) (map[string]any, error) {
+	return nil, errors.New("sensor offline")
+}
+
+// copingNode sums the inputs it has got, whether its upstream nodes have failed or not.
+type copingNode struct {
+	*SumNode
+}
+
+func (n *copingNode) DataE(inputs func() (map[string]any, error)) (map[string]any, error) {
+	values, _ := inputs()
+	return n.SumNode.Data(func() map[string]any { return values }), nil
+}
+
+type panickingNode struct {
+	*StepNode
+}
+
+func (n *panickingNode) Execute(string, func(string)) {
+	panic("boom")
+}
+
+func TestEnginesAggregateNodeErrors(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	broken, one, total := &failingNode{NewNumberNode(0)}, NewNumberNode(1), NewSumNode()
+	editor.AddNode(broken)
+	editor.AddNode(one)
+	editor.AddNode(total)
+	editor.AddConnection(src.NewConnection(broken, "value", total, "a"))
+	editor.AddConnection(src.NewConnection(one, "value", total, "b"))
+
+	_, err := src.NewDataflowEngine(editor, nil).Fetch(total.Node().E.ID)
+
+	var graphErr *src.GraphError
+	if !errors.As(err, &graphErr) || len(graphErr.Errors) != 1 {
+		t.Fatalf("expected a GraphError with a single failure, got %v", err)
+	}
+
+	nodeErr := graphErr.Errors[0]
+	if nodeErr.NodeId != broken.Node().E.ID || nodeErr.Input != "a" || len(nodeErr.Path) != 2 || nodeErr.Path[0] != total.Node().E.ID {
+		t.Errorf("unexpected node error %+v", nodeErr)
+	}
+	if one.Calls != 1 {
+		t.Error("the independent branch should have been evaluated")
+	}
+
+	coping, downstream := &copingNode{NewSumNode()}, NewSumNode()
+	editor.AddNode(coping)
+	editor.AddNode(downstream)
+	editor.AddConnection(src.NewConnection(broken, "value", coping, "a"))
+	editor.AddConnection(src.NewConnection(one, "value", coping, "b"))
+	editor.AddConnection(src.NewConnection(coping, "value", downstream, "a"))
+
+	var engine = src.NewDataflowEngine(editor, nil)
+	for i := 0; i < 2; i++ {
+		outputs, err := engine.Fetch(downstream.Node().E.ID)
+		if !errors.As(err, &graphErr) || outputs["value"] != 1.0 {
+			t.Fatalf("expected the outputs of the coping node with its upstream failure, got %v and %v", outputs, err)
+		}
+	}
+	if coping.Calls != 2 || downstream.Calls != 2 {
+		t.Errorf("expected the outputs computed despite a failure not to be cached, got %d and %d calls", coping.Calls, downstream.Calls)
+	}
+
+	var visited []string
+	start, ok := NewStepNode("start", &visited), NewStepNode("ok", &visited)
+	panics := &panickingNode{NewStepNode("panics", &visited)}
+	for _, node := range []src.NodeInterface{start, panics, ok} {
+		editor.AddNode(node)
+	}
+	editor.AddConnection(src.NewConnection(start, "exec", panics, "exec"))
+	editor.AddConnection(src.NewConnection(start, "exec", ok, "exec"))
+
+	err = src.NewControlFlowEngine(editor, nil).Execute(start.Node().E.ID, "trigger")
+	if !errors.As(err, &graphErr) || len(graphErr.Errors) != 1 || graphErr.Errors[0].NodeId != panics.Node().E.ID {
+		t.Fatalf("expected the panic to be reported, got %v", err)
+	}
+	if len(visited) != 2 {
+		t.Errorf("the other branch should have been executed, visited %v", visited)
+	}
+}
+
+// BenchmarkDataflowEngineChain fetches the end of a long chain with a cold cache, every node looks up
+// its incoming connections once.
+func BenchmarkDataflowEngineChain(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const nodes = 10_000
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var previous src.NodeInterface = NewNumberNode(1)
+	editor.AddNode(previous)
+	for i := 1; i < nodes; i++ {
+		var sum = NewSumNode()
+		editor.AddNode(sum)
+		editor.AddConnection(src.NewConnection(previous, "value", sum, "a"))
+		previous = sum
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		outputs, err := src.NewDataflowEngine(editor, nil).Fetch(previous.Node().E.ID)
+		if err != nil || outputs["value"] != 1.0 {
+			b.Fatalf("expected 1, got %v %v", outputs["value"], err)
+		}
+	}
+}
@@ -0,0 +1,799 @@
+package test
+
+import (
+	"errors"
+	"github.com/ashkan90/auto-core/src"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestEditorTopologicalOrder(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	one, two, sum := NewNumberNode(1), NewNumberNode(2), NewSumNode()
+	editor.AddNode(sum)
+	editor.AddNode(one)
+	editor.AddNode(two)
+	editor.AddConnection(src.NewConnection(one, "value", sum, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", sum, "b"))
+
+	order, err := editor.TopologicalOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 3 || order[2] != sum.Node().E.ID {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestEditorCycles(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	a, b, c := NewSumNode(), NewSumNode(), NewSumNode()
+	editor.AddNode(a)
+	editor.AddNode(b)
+	editor.AddNode(c)
+	editor.AddConnection(src.NewConnection(a, "value", b, "a"))
+	editor.AddConnection(src.NewConnection(b, "value", c, "a"))
+
+	var engine = src.NewDataflowEngine(editor, nil)
+	if _, err := engine.Fetch(c.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := editor.SetDAGMode(true); err != nil {
+		t.Fatal(err)
+	}
+
+	var cycleErr *src.CycleError
+	if err := editor.AddConnection(src.NewConnection(c, "value", a, "a")); !errors.As(err, &cycleErr) {
+		t.Fatalf("expected a CycleError in DAG mode, got %v", err)
+	}
+
+	editor.SetDAGMode(false)
+	closing := src.NewConnection(c, "value", a, "a")
+	if err := editor.AddConnection(closing); err != nil {
+		t.Fatal(err)
+	}
+
+	cycles := editor.FindCycles()
+	if len(cycles) != 1 || len(cycles[0]) != 3 || !slices.Contains(cycles[0], b.Node().E.ID) {
+		t.Errorf("unexpected cycles %v", cycles)
+	}
+
+	if _, err := editor.TopologicalOrder(); !errors.As(err, &cycleErr) {
+		t.Errorf("expected a CycleError, got %v", err)
+	}
+	if _, err := engine.Fetch(c.Node().E.ID); !errors.As(err, &cycleErr) {
+		t.Errorf("expected the dataflow engine to refuse the loop, got %v", err)
+	}
+	if err := editor.SetDAGMode(true); err == nil {
+		t.Error("expected DAG mode to be refused on a cyclic graph")
+	}
+
+	editor.RemoveConnection(closing.E.ID)
+	if err := editor.SetDAGMode(true); err != nil {
+		t.Fatal(err)
+	}
+	err := editor.Transaction(func(tx *src.NodeEditor) error {
+		return tx.AddConnection(src.NewConnection(c, "value", a, "a"))
+	})
+	if !errors.As(err, &cycleErr) || len(cycleErr.Cycles[0]) != 3 {
+		t.Errorf("expected a CycleError in a DAG mode transaction, got %v", err)
+	}
+}
+
+func TestEditorOrdering(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	editor.SetOrdering(src.OrderingInsertion)
+
+	var visited []string
+	var start = NewStepNode("start", &visited)
+	editor.AddNode(start)
+
+	var names = []string{"e", "b", "d", "a", "c"}
+	var ids = []src.NodeId{start.Node().E.ID}
+	for _, name := range names {
+		node := NewStepNode(name, &visited)
+		editor.AddNode(node)
+		editor.AddConnection(src.NewConnection(start, "exec", node, "exec"))
+		ids = append(ids, node.Node().E.ID)
+	}
+
+	for run := 0; run < 5; run++ {
+		for i, node := range editor.GetNodes() {
+			if node.Node().E.ID != ids[i] {
+				t.Fatalf("nodes aren't in insertion order")
+			}
+		}
+
+		visited = nil
+		src.NewControlFlowEngine(editor, nil).Execute(start.Node().E.ID, "trigger")
+		for i, name := range names {
+			if visited[i+1] != name+":exec" {
+				t.Fatalf("unexpected execution order %v", visited)
+			}
+		}
+	}
+
+	sum, first, second := NewSumNode(), NewNumberNode(1), NewNumberNode(2)
+	editor.AddNode(sum)
+	editor.AddNode(first)
+	editor.AddNode(second)
+
+	input, _ := sum.Node().Inputs.Get("a")
+	input.(*src.Input[src.Socket]).Port.(*src.Port[src.Socket]).Index = 1
+
+	editor.AddConnection(src.NewConnection(first, "value", sum, "a"))
+	editor.AddConnection(src.NewConnection(second, "value", sum, "b"))
+	editor.SetOrdering(src.OrderingIndex)
+
+	conns := editor.GetConnectionsTo(sum.Node().E.ID, []string{"a", "b"})
+	if len(conns) != 2 || conns[0].TargetInput != "b" || conns[1].TargetInput != "a" {
+		t.Errorf("connections aren't sorted by port index")
+	}
+}
+
+func TestEditorSocketCompatibility(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	var visited []string
+	step, sum := NewStepNode("step", &visited), NewSumNode()
+	editor.AddNode(step)
+	editor.AddNode(sum)
+
+	if _, err := src.TryNewConnection(step, "exec", sum, "a"); err == nil {
+		t.Fatal("expected exec and number sockets to be incompatible")
+	}
+
+	var conn = &src.Connection[src.ConnectionBase]{
+		E:            src.ConnectionBase{ID: "conn"},
+		Source:       step.Node().E.ID,
+		SourceOutput: "exec",
+		Target:       sum.Node().E.ID,
+		TargetInput:  "a",
+	}
+
+	var incompatible *src.IncompatibleSocketsError
+	if err := editor.AddConnection(conn); !errors.As(err, &incompatible) || incompatible.InputSocket.Name != "number" {
+		t.Fatalf("expected an IncompatibleSocketsError, got %v", err)
+	}
+
+	var registry = src.NewSocketRegistry()
+	registry.Allow("exec", "number")
+	editor.SetSocketRegistry(registry)
+
+	allowed, err := src.TryNewConnectionWithRegistry(registry, step, "exec", sum, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.AddConnection(allowed); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.TryNewConnectionWithRegistry(registry, step, "exec", sum, "c"); err == nil {
+		t.Error("expected an error for a missing input")
+	}
+
+	var missing = &src.Connection[src.ConnectionBase]{
+		E:            src.ConnectionBase{ID: "missing"},
+		Source:       step.Node().E.ID,
+		SourceOutput: "exec",
+		Target:       sum.Node().E.ID,
+		TargetInput:  "c",
+	}
+	if err := editor.AddConnection(missing); err == nil {
+		t.Error("expected an error for a missing input")
+	}
+	if _, err := editor.GetConnection(allowed.E.ID); err != nil {
+		t.Error("expected the allowed connection to stay in the editor")
+	}
+}
+
+func TestEditorConnectionPolicy(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+
+	var removed []src.ConnectionId
+	var committed [][]src.Event
+	bus.Subscribe("connectionRemoved", func(event src.Event) {
+		removed = append(removed, event.Data.(src.ConnectionId))
+	})
+	bus.Subscribe("transactionCommitted", func(event src.Event) {
+		committed = append(committed, event.Data.([]src.Event))
+	})
+
+	one, two, sum := NewNumberNode(1), NewNumberNode(2), NewSumNode()
+	editor.AddNode(one)
+	editor.AddNode(two)
+	editor.AddNode(sum)
+	var history = src.NewHistory(editor, nil)
+
+	var first = src.NewConnection(one, "value", sum, "a")
+	if err := editor.AddConnection(first); err != nil {
+		t.Fatal(err)
+	}
+
+	var occupied *src.PortOccupiedError
+	if err := editor.AddConnection(src.NewConnection(two, "value", sum, "a")); !errors.As(err, &occupied) || occupied.Side != "input" {
+		t.Fatalf("expected a PortOccupiedError for input a, got %v", err)
+	}
+
+	// outputs accept multiple connections
+	if err := editor.AddConnection(src.NewConnection(one, "value", sum, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	editor.SetConnectionPolicy(src.ConnectionPolicyReplace)
+	var second = src.NewConnection(two, "value", sum, "a")
+	if err := editor.AddConnection(second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(removed, []src.ConnectionId{first.E.ID}) || len(committed) != 0 {
+		t.Errorf("expected %s to be removed with a connectionRemoved event, got %v %v", first.E.ID, removed, committed)
+	}
+	if conns := editor.GetConnectionsTo(sum.Node().E.ID, []string{"a"}); len(conns) != 1 || conns[0] != second {
+		t.Errorf("unexpected connections of input a %v", conns)
+	}
+
+	// in a transaction the replacement is a single change
+	var third = src.NewConnection(one, "value", sum, "a")
+	if err := editor.Transaction(func(tx *src.NodeEditor) error {
+		return tx.AddConnection(third)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(committed) != 1 || len(committed[0]) != 2 ||
+		committed[0][0].Data != second.E.ID || committed[0][1].Data != third {
+		t.Errorf("expected %s to be replaced in a single change, got %v", second.E.ID, committed)
+	}
+
+	if err := history.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if conns := editor.GetConnectionsTo(sum.Node().E.ID, []string{"a"}); len(conns) != 1 || conns[0] != second {
+		t.Errorf("expected a single undo to restore %s, got %v", second.E.ID, conns)
+	}
+}
+
+func TestEditorCascadingRemoval(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+
+	var events []string
+	for _, eventType := range []src.EventType{"connectionRemoved", "nodeRemoved", "inputRemoved"} {
+		bus.Subscribe(eventType, func(event src.Event) {
+			events = append(events, string(event.Type))
+		})
+	}
+
+	one, two, sum := NewNumberNode(1), NewNumberNode(2), NewSumNode()
+	editor.AddNode(one)
+	editor.AddNode(two)
+	editor.AddNode(sum)
+	editor.AddConnection(src.NewConnection(one, "value", sum, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", sum, "b"))
+
+	sum.Node().RemoveInput("b")
+	if len(editor.GetConnections()) != 1 || sum.Node().HasInput("b") {
+		t.Fatalf("expected the connection of input b to be removed, got %v", editor.GetConnections())
+	}
+
+	editor.RemoveNode(sum.Node().E.ID)
+	if len(editor.GetConnections()) != 0 {
+		t.Fatalf("expected the connections of the node to be removed, got %v", editor.GetConnections())
+	}
+
+	var expected = []string{"connectionRemoved", "inputRemoved", "connectionRemoved", "nodeRemoved"}
+	if !slices.Equal(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+}
+
+func TestEditorConcurrentPortRemoval(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		var editor = src.NewNodeEditor(src.NewEventBus())
+
+		one, sum := NewNumberNode(1), NewSumNode()
+		editor.AddNode(one)
+		editor.AddNode(sum)
+		editor.AddConnection(src.NewConnection(one, "value", sum, "a"))
+		editor.AddConnection(src.NewConnection(one, "value", sum, "b"))
+
+		ctrl, _ := one.Node().Controls.Get("value")
+
+		var wg sync.WaitGroup
+		for _, change := range []func(){
+			func() { editor.RemoveNode(sum.Node().E.ID) },
+			func() { sum.Node().RemoveInput("a") },
+			func() { one.Node().RemoveOutput("value") },
+			func() { editor.RemoveNode(one.Node().E.ID) },
+			func() { ctrl.(*src.InputControl).SetValue(2.0) },
+		} {
+			wg.Add(1)
+			go func(change func()) {
+				defer wg.Done()
+				change()
+			}(change)
+		}
+		wg.Wait()
+
+		if conns := editor.GetConnections(); len(conns) != 0 || sum.Node().HasInput("a") || one.Node().HasOutput("value") {
+			t.Fatalf("expected every port and connection to be removed, got %v", conns)
+		}
+	}
+}
+
+func TestEditorSignals(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+
+	var protected = NewNumberNode(1)
+	bus.Subscribe("nodeRemove", func(event src.Event) {
+		signal := event.Data.(*src.Signal)
+		if signal.Data.(src.NodeId) == protected.Node().E.ID {
+			signal.Cancel(errors.New("only admins may delete this node"))
+		}
+	})
+
+	var labelled = NewNumberNode(2)
+	bus.Subscribe("nodeCreate", func(event src.Event) {
+		signal := event.Data.(*src.Signal)
+		if signal.Data == src.NodeInterface(labelled) {
+			signal.Data = NewNumberNode(3)
+		}
+	})
+
+	editor.AddNode(protected)
+	added, err := editor.AddNode(labelled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added == src.NodeInterface(labelled) {
+		t.Error("expected the node to be replaced by the subscriber")
+	}
+
+	var cancelled *src.SignalCancelledError
+	if _, err := editor.RemoveNode(protected.Node().E.ID); !errors.As(err, &cancelled) || cancelled.Type != "nodeRemove" {
+		t.Fatalf("expected a SignalCancelledError, got %v", err)
+	}
+	if _, err := editor.GetNode(protected.Node().E.ID); err != nil {
+		t.Error("expected the node not to be removed")
+	}
+
+	one, two, sum := NewNumberNode(1), NewNumberNode(2), NewSumNode()
+	editor.AddNode(one)
+	editor.AddNode(two)
+	editor.AddNode(sum)
+	var kept = src.NewConnection(one, "value", sum, "a")
+	editor.AddConnection(kept)
+	bus.Subscribe("connectionRemove", func(event src.Event) {
+		signal := event.Data.(*src.Signal)
+		if signal.Data.(src.ConnectionId) == kept.E.ID {
+			signal.Cancel(errors.New("only admins may delete this connection"))
+		}
+	})
+
+	if _, err := editor.RemoveNode(one.Node().E.ID); !errors.As(err, &cancelled) || cancelled.Type != "connectionRemove" {
+		t.Errorf("expected the cascading removal to be cancelled, got %v", err)
+	}
+	editor.SetConnectionPolicy(src.ConnectionPolicyReplace)
+	if err := editor.AddConnection(src.NewConnection(two, "value", sum, "a")); !errors.As(err, &cancelled) {
+		t.Errorf("expected the replacement to be cancelled, got %v", err)
+	}
+	sum.Node().RemoveInput("a")
+
+	if _, err := editor.GetNode(one.Node().E.ID); err != nil || !sum.Node().HasInput("a") {
+		t.Error("expected the node and the input not to be removed")
+	}
+	if conns := editor.GetConnections(); len(conns) != 1 || conns[0] != kept {
+		t.Errorf("expected only the protected connection, got %v", conns)
+	}
+
+	// a connection added while the removal is being signalled is signalled too
+	var other = NewSumNode()
+	editor.AddNode(other)
+	var early, late = src.NewConnection(two, "value", sum, "b"), src.NewConnection(two, "value", other, "a")
+	editor.AddConnection(early)
+
+	var signalled []src.ConnectionId
+	bus.Subscribe("connectionRemove", func(event src.Event) {
+		var connID = event.Data.(*src.Signal).Data.(src.ConnectionId)
+		if connID == early.E.ID {
+			editor.AddConnection(late)
+		}
+		signalled = append(signalled, connID)
+	})
+	if _, err := editor.RemoveNode(two.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(signalled, []src.ConnectionId{early.E.ID, late.E.ID}) || len(editor.GetConnections()) != 1 {
+		t.Errorf("expected the late connection to be signalled and removed, got %v", signalled)
+	}
+}
+
+func TestEditorTransaction(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+
+	var batches [][]src.Event
+	bus.Subscribe("transactionCommitted", func(event src.Event) {
+		batches = append(batches, event.Data.([]src.Event))
+	})
+	bus.Subscribe("nodeCreated", func(event src.Event) {
+		t.Error("expected the changes to be batched")
+	})
+
+	one, sum := NewNumberNode(1), NewSumNode()
+	err := editor.Transaction(func(tx *src.NodeEditor) error {
+		tx.AddNode(one)
+		tx.AddNode(sum)
+		if len(editor.GetNodes()) != 0 {
+			t.Error("expected the changes not to be visible before the commit")
+		}
+		return tx.AddConnection(src.NewConnection(one, "value", sum, "a"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(editor.GetNodes()) != 2 || len(editor.GetConnections()) != 1 {
+		t.Fatalf("expected the changes to be applied, got %v", editor.GetConnections())
+	}
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("expected a single batch of 3 events, got %v", batches)
+	}
+
+	// a failing change rolls the whole transaction back
+	err = editor.Transaction(func(tx *src.NodeEditor) error {
+		tx.RemoveNode(one.Node().E.ID)
+		return tx.AddConnection(src.NewConnection(one, "value", sum, "b"))
+	})
+	if err == nil {
+		t.Fatal("expected the transaction to fail")
+	}
+	if len(editor.GetNodes()) != 2 || len(editor.GetConnections()) != 1 {
+		t.Error("expected the failed transaction to be rolled back")
+	}
+
+	err = editor.Transaction(func(tx *src.NodeEditor) error {
+		tx.RemoveConnection(editor.GetConnections()[0].E.ID)
+		editor.RemoveNode(sum.Node().E.ID)
+		return nil
+	})
+	if !errors.Is(err, src.ErrTransactionConflict) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var history = src.NewHistory(editor, &src.HistoryOptions{Depth: 10})
+
+	one, two, sum := NewNumberNode(1), NewNumberNode(2), NewSumNode()
+	history.Group(func() error {
+		editor.AddNode(one)
+		editor.AddNode(two)
+		editor.AddNode(sum)
+		return nil
+	})
+	editor.AddConnection(src.NewConnection(one, "value", sum, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", sum, "b"))
+
+	editor.RemoveNode(sum.Node().E.ID)
+	if err := history.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if len(editor.GetNodes()) != 3 || len(editor.GetConnections()) != 2 {
+		t.Fatalf("expected the node to come back with its connections, got %v", editor.GetConnections())
+	}
+
+	control, _ := one.Node().Controls.Get("value")
+	control.(*src.InputControl).SetValue(5.0)
+	history.Undo()
+	if value := control.(*src.InputControl).GetValue(); value != 1.0 {
+		t.Errorf("expected the control value to be reverted, got %v", value)
+	}
+
+	// the connections, then the grouped nodes
+	for i := 0; i < 3; i++ {
+		if err := history.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(editor.GetNodes()) != 0 || history.CanUndo() {
+		t.Fatalf("expected an empty editor, got %v", editor.GetNodes())
+	}
+
+	for history.CanRedo() {
+		if err := history.Redo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(editor.GetNodes()) != 3 || len(editor.GetConnections()) != 2 {
+		t.Errorf("expected the graph to be rebuilt, got %v", editor.GetConnections())
+	}
+
+	history.Close()
+	editor.AddNode(NewNumberNode(4))
+	if history.CanUndo() || history.CanRedo() {
+		t.Error("expected a closed history not to record anything")
+	}
+}
+
+func TestEditorQueries(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	editor.SetOrdering(src.OrderingInsertion)
+
+	one, two := NewNumberNode(1), NewNumberNode(2)
+	left, right, total := NewSumNode(), NewSumNode(), NewSumNode()
+	for _, node := range []src.NodeInterface{one, two, left, right, total} {
+		editor.AddNode(node)
+	}
+	editor.AddConnection(src.NewConnection(one, "value", left, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", left, "b"))
+	editor.AddConnection(src.NewConnection(one, "value", right, "a"))
+	editor.AddConnection(src.NewConnection(left, "value", total, "a"))
+	editor.AddConnection(src.NewConnection(right, "value", total, "b"))
+
+	var id = func(node src.NodeInterface) src.NodeId { return node.Node().E.ID }
+
+	if conns := editor.GetConnectionsFrom(id(one), []string{"value"}); len(conns) != 2 {
+		t.Errorf("expected 2 connections from one, got %v", conns)
+	}
+	if got := editor.Predecessors(id(left)); !slices.Equal(got, []src.NodeId{id(one), id(two)}) {
+		t.Errorf("unexpected predecessors %v", got)
+	}
+	if got := editor.Successors(id(one)); !slices.Equal(got, []src.NodeId{id(left), id(right)}) {
+		t.Errorf("unexpected successors %v", got)
+	}
+	if got := editor.Upstream(id(total)); !slices.Equal(got, []src.NodeId{id(left), id(right), id(one), id(two)}) {
+		t.Errorf("unexpected upstream %v", got)
+	}
+	if got := editor.Downstream(id(two)); !slices.Equal(got, []src.NodeId{id(left), id(total)}) {
+		t.Errorf("unexpected downstream %v", got)
+	}
+	if paths := editor.AllPaths(id(one), id(total)); len(paths) != 2 || len(paths[0]) != 3 {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	input, _ := left.Node().Inputs.Get("b")
+	port := input.(*src.Input[src.Socket]).Port.(*src.Port[src.Socket])
+	node, key, err := editor.GetNodeByPort(port.Id)
+	if err != nil || node != src.NodeInterface(left) || key != "b" {
+		t.Errorf("unexpected port owner %v %s %v", node, key, err)
+	}
+}
+
+func TestEditorConnectionIndex(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+	var visited []string
+	one, two := NewStepNode("one", &visited), NewStepNode("two", &visited)
+	editor.AddNode(one)
+	editor.AddNode(two)
+
+	var forward, loop = src.NewConnection(one, "exec", two, "exec"), src.NewConnection(two, "exec", two, "exec")
+	editor.AddConnection(forward)
+	editor.AddConnection(loop)
+
+	var ids = func(conns []*src.Connection[src.ConnectionBase]) []src.ConnectionId {
+		var ids = []src.ConnectionId{}
+		for _, conn := range conns {
+			ids = append(ids, conn.E.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	var sorted = func(ids ...src.ConnectionId) []src.ConnectionId {
+		slices.Sort(ids)
+		return ids
+	}
+
+	if got := ids(editor.GetConnectionsTo(two.Node().E.ID, []string{"exec"})); !slices.Equal(got, sorted(forward.E.ID, loop.E.ID)) {
+		t.Errorf("unexpected connections to two %v", got)
+	}
+	if got := ids(editor.GetConnectionsFrom(two.Node().E.ID, []string{"exec", "value"})); !slices.Equal(got, sorted(loop.E.ID)) {
+		t.Errorf("unexpected connections from two %v", got)
+	}
+
+	// a rolled back transaction leaves the index as it was
+	editor.Transaction(func(tx *src.NodeEditor) error {
+		tx.RemoveConnection(forward.E.ID)
+		return errors.New("rollback")
+	})
+	if got := ids(editor.GetConnectionsFrom(one.Node().E.ID, []string{"exec"})); !slices.Equal(got, sorted(forward.E.ID)) {
+		t.Errorf("unexpected connections from one %v", got)
+	}
+
+	editor.Transaction(func(tx *src.NodeEditor) error {
+		_, err := tx.RemoveNode(two.Node().E.ID)
+		return err
+	})
+	if got := ids(editor.GetConnectionsFrom(one.Node().E.ID, []string{"exec"})); len(got) != 0 || len(editor.GetConnections()) != 0 {
+		t.Errorf("expected the connections of two to be removed, got %v", got)
+	}
+}
+
+func TestEditorCopyPaste(t *testing.T) {
+	var editor = src.NewNodeEditor(src.NewEventBus())
+
+	one, two, sum := NewNumberNode(1), NewNumberNode(2), NewSumNode()
+	editor.AddNode(one)
+	editor.AddNode(two)
+	editor.AddNode(sum)
+	editor.AddConnection(src.NewConnection(one, "value", sum, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", sum, "b"))
+
+	var selection = []src.NodeId{one.Node().E.ID, sum.Node().E.ID}
+
+	ids, err := editor.Duplicate(selection, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(editor.GetNodes()) != 5 || len(editor.GetConnections()) != 3 {
+		t.Fatalf("expected the inner connection only, got %v", editor.GetConnections())
+	}
+
+	var copied = ids[sum.Node().E.ID]
+	conns := editor.GetConnectionsTo(copied, []string{"a", "b"})
+	if len(conns) != 1 || conns[0].Source != ids[one.Node().E.ID] {
+		t.Fatalf("expected the copy to be wired to the copied source, got %v", conns)
+	}
+
+	original, _ := sum.Node().Inputs.Get("a")
+	duplicated, _ := editor.GetNode(copied)
+	input, _ := duplicated.Node().Inputs.Get("a")
+	if input.(*src.Input[src.Socket]).Port.GetId() == original.(*src.Input[src.Socket]).Port.GetId() {
+		t.Error("expected the ports to get new ids")
+	}
+
+	clipboard, err := editor.Copy(selection, &src.CopyOptions{Boundary: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the boundary connection from `two` is kept in the same editor and dropped in another one
+	if _, err := editor.Paste(clipboard); err != nil {
+		t.Fatal(err)
+	}
+	if len(editor.GetConnections()) != 5 {
+		t.Errorf("expected the boundary connection to be kept, got %v", editor.GetConnections())
+	}
+
+	var other = src.NewNodeEditor(src.NewEventBus())
+	if _, err := other.Paste(clipboard); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.GetNodes()) != 2 || len(other.GetConnections()) != 1 {
+		t.Errorf("expected the boundary connection to be dropped, got %v", other.GetConnections())
+	}
+}
+
+func TestEditorImport(t *testing.T) {
+	var fragment = func() (*src.JSONEditor, *NumberNode, *SumNode) {
+		one, sum := NewNumberNode(1), NewSumNode()
+		var conn = src.NewConnection(one, "value", sum, "a")
+		return &src.JSONEditor{
+			Nodes:       map[src.NodeId]src.NodeInterface{one.Node().E.ID: one, sum.Node().E.ID: sum},
+			Connections: map[src.ConnectionId]*src.Connection[src.ConnectionBase]{conn.E.ID: conn},
+		}, one, sum
+	}
+
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+	var created, added, committed int
+	bus.Subscribe("nodeCreated", func(src.Event) { created++ })
+	bus.Subscribe("connectionAdded", func(src.Event) { added++ })
+	bus.Subscribe("transactionCommitted", func(src.Event) { committed++ })
+
+	input, one, sum := fragment()
+	if _, err := editor.Import(input, src.ImportSkip); err != nil {
+		t.Fatal(err)
+	}
+	if created != 2 || added != 1 || committed != 0 || len(editor.GetConnections()) != 1 {
+		t.Fatalf("expected the fragment to be imported with its events, got %d nodeCreated, %d connectionAdded and %d transactionCommitted", created, added, committed)
+	}
+
+	// the same graph decoded again has the same ids
+	var decode = func() *src.JSONEditor {
+		clipboard, _ := editor.Copy([]src.NodeId{one.Node().E.ID, sum.Node().E.ID}, nil)
+		data, _ := src.NewJSONEditorData(clipboard)
+		decoded, _ := src.NewJSONEditor(data)
+		return decoded
+	}
+
+	if _, err := editor.Import(decode(), src.ImportSkip); err != nil || len(editor.GetNodes()) != 2 {
+		t.Fatalf("expected the conflicts to be skipped, got %v", err)
+	}
+
+	var decoded = decode()
+	ids, err := editor.Import(decoded, src.ImportRemap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(editor.GetNodes()) != 4 || len(editor.GetConnections()) != 2 || ids[one.Node().E.ID] == one.Node().E.ID {
+		t.Fatalf("expected the fragment to be remapped, got %v", ids)
+	}
+	if decoded.Nodes[one.Node().E.ID].Node().E.ID != one.Node().E.ID {
+		t.Error("expected the input nodes not to be changed")
+	}
+
+	// a failing change leaves the editor as it was
+	broken, _, _ := fragment()
+	for _, conn := range broken.Connections {
+		conn.Target = "missing"
+	}
+	if _, err := editor.Import(broken, src.ImportSkip); err == nil || len(editor.GetNodes()) != 4 {
+		t.Fatalf("expected nothing to be imported, got %v", err)
+	}
+
+	var removed int
+	bus.Subscribe("nodeRemoved", func(src.Event) { removed++ })
+	if err := editor.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if removed != 4 || len(editor.GetNodes()) != 0 || len(editor.GetConnections()) != 0 {
+		t.Errorf("expected an empty editor, got %d removals", removed)
+	}
+	// in a transaction the import is published at once
+	created, committed = 0, 0
+	input, _, _ = fragment()
+	if err := editor.Transaction(func(tx *src.NodeEditor) error {
+		_, err := tx.Import(input, src.ImportSkip)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if created != 0 || committed != 1 || len(editor.GetNodes()) != 2 {
+		t.Errorf("expected a single transactionCommitted, got %d nodeCreated and %d transactionCommitted", created, committed)
+	}
+}
+
+func TestEditorSelection(t *testing.T) {
+	var bus = src.NewEventBus()
+	var editor = src.NewNodeEditor(bus)
+
+	var events []string
+	for _, eventType := range []src.EventType{"nodeSelected", "nodeUnselected"} {
+		bus.Subscribe(eventType, func(event src.Event) {
+			events = append(events, string(event.Type))
+		})
+	}
+
+	one, two, sum, lonely := NewNumberNode(1), NewNumberNode(2), NewSumNode(), NewSumNode()
+	for _, node := range []src.NodeInterface{one, two, sum, lonely} {
+		editor.AddNode(node)
+	}
+	editor.AddConnection(src.NewConnection(one, "value", sum, "a"))
+	editor.AddConnection(src.NewConnection(two, "value", sum, "b"))
+
+	if err := editor.SelectComponent(one.Node().E.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(editor.Selected()) != 3 || len(events) != 3 {
+		t.Fatalf("expected the component to be selected, got %v", editor.Selected())
+	}
+
+	editor.ToggleSelection(two.Node().E.ID)
+	if err := editor.Select(lonely.Node().E.ID, "missing"); err == nil || slices.Contains(editor.Selected(), lonely.Node().E.ID) {
+		t.Error("expected nothing to be selected when a node doesn't exist")
+	}
+
+	ids, err := editor.DuplicateSelected(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected := editor.Selected(); len(selected) != 2 || !slices.Contains(selected, ids[sum.Node().E.ID]) {
+		t.Errorf("expected the copies to be selected, got %v", selected)
+	}
+
+	if err := editor.RemoveSelected(); err != nil {
+		t.Fatal(err)
+	}
+	if len(editor.GetNodes()) != 4 || len(editor.Selected()) != 0 {
+		t.Errorf("expected the copies to be removed, got %v", editor.GetNodes())
+	}
+}
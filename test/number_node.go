@@ -0,0 +1,68 @@
+package test
+
+import (
+	"github.com/ashkan90/auto-core/src"
+)
+
+type NumberNode struct {
+	src.NodeInterface
+	Calls int
+}
+
+func (n *NumberNode) Data(_ func() map[string]any) map[string]any {
+	n.Calls++
+	ctrl, _ := n.Node().Controls.Get("value")
+	value := ctrl.(*src.InputControl).GetValue()
+	if ptr, ok := value.(*any); ok {
+		value = *ptr
+	}
+	return map[string]any{"value": value}
+}
+
+func NewNumberNode(value float64) *NumberNode {
+	var node = src.NewNode()
+	node.AddControl("value", src.NewInputControl(src.InputControlNumber, &src.InputControlOptions{
+		Readonly: src.ToPtr(false),
+		Initial:  value,
+	}))
+	node.AddOutput("value", src.NewOutput[src.Socket](src.NewSocket("number"), "Value", true))
+
+	return &NumberNode{NodeInterface: node}
+}
+
+type SumNode struct {
+	src.NodeInterface
+	Calls int
+}
+
+func (n *SumNode) Data(inputs func() map[string]any) map[string]any {
+	n.Calls++
+	var sum float64
+	for _, values := range inputs() {
+		for _, value := range values.([]any) {
+			if v, ok := value.(float64); ok {
+				sum += v
+			}
+		}
+	}
+	return map[string]any{"value": sum}
+}
+
+func NewSumNode() *SumNode {
+	var node = src.NewNode()
+	node.AddInput("a", src.NewInput[src.Socket](src.NewSocket("number"), "A", false))
+	node.AddInput("b", src.NewInput[src.Socket](src.NewSocket("number"), "B", false))
+	node.AddOutput("value", src.NewOutput[src.Socket](src.NewSocket("number"), "Value", true))
+
+	return &SumNode{NodeInterface: node}
+}
+
+// NewTotalNode creates a SumNode whose `a` input accepts multiple connections.
+func NewTotalNode() *SumNode {
+	var node = src.NewNode()
+	node.AddInput("a", src.NewInput[src.Socket](src.NewSocket("number"), "A", true))
+	node.AddInput("b", src.NewInput[src.Socket](src.NewSocket("number"), "B", false))
+	node.AddOutput("value", src.NewOutput[src.Socket](src.NewSocket("number"), "Value", true))
+
+	return &SumNode{NodeInterface: node}
+}
@@ -0,0 +1,25 @@
+package test
+
+import (
+	"github.com/ashkan90/auto-core/src"
+)
+
+type StepNode struct {
+	src.NodeInterface
+	Name    string
+	Visited *[]string
+}
+
+func (n *StepNode) Execute(input string, forward func(output string)) {
+	*n.Visited = append(*n.Visited, n.Name+":"+input)
+	forward("exec")
+}
+
+func NewStepNode(name string, visited *[]string) *StepNode {
+	var node = src.NewNode()
+	node.AddInput("exec", src.NewInput[src.Socket](src.NewSocket("exec"), "exec", true))
+	node.AddOutput("exec", src.NewOutput[src.Socket](src.NewSocket("exec"), "exec", true))
+	node.AddOutput("value", src.NewOutput[src.Socket](src.NewSocket("number"), "Value", true))
+
+	return &StepNode{NodeInterface: node, Name: name, Visited: visited}
+}
@@ -24,7 +24,15 @@ func (n *TextNode) Execute(input string, forward func(output string)) {
 }
 
 func NewTextNode() src.NodeInterface {
+	var node = src.NewNode()
+	node.AddControl("text", src.NewInputControl(src.InputControlText, &src.InputControlOptions{
+		Readonly: src.ToPtr(false),
+		Initial:  "",
+	}))
+	node.AddInput("exec", src.NewInput[src.Socket](src.NewSocket("exec"), "exec", true))
+	node.AddOutput("exec", src.NewOutput[src.Socket](src.NewSocket("exec"), "exec", true))
+
 	return &TextNode{
-		NodeInterface: src.NewNode(),
+		NodeInterface: node,
 	}
 }